@@ -9,19 +9,41 @@ import (
 	"strconv"
 )
 
-var lConfig = &server.Configuration{}
+var (
+	lConfig    = &server.Configuration{}
+	lHeaders   []string
+	lRedirects []string
+	lFaults    []string
+	lVHosts    []string
+)
 
 var localCmd = &cobra.Command{
-	Use:     "local [path]",
+	Use:     "local [path[:prefix[:options]]]...",
 	Aliases: []string{"l"},
 	Short:   "Start a local HTTP server",
-	Args:    cobra.MaximumNArgs(2),
+	Args:    cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) > 0 {
-			lConfig.Path = args[0]
-		}
 		lConfig.Type = server.TypeLocal
+		if err := server.CheckETagMode(lConfig.ETag); err != nil {
+			log.Fatal("Invalid ETag", "error", err)
+		}
+		if err := server.CheckSymlinkPolicy(lConfig.Symlinks); err != nil {
+			log.Fatal("Invalid symlinks", "error", err)
+		}
 		lConfig.Path = "./"
+		mounts, err := server.ParseMounts(args, *lConfig)
+		if err != nil {
+			log.Fatal("Invalid path", "error", err)
+		}
+		lConfig.Mounts = mounts
+		vhosts, err := server.ParseVHosts(lVHosts, *lConfig)
+		if err != nil {
+			log.Fatal("Invalid virtual host", "error", err)
+		}
+		lConfig.VHosts = vhosts
+		lConfig.Headers = parseHeaders(lHeaders)
+		lConfig.Redirects = parseRedirects(lRedirects)
+		lConfig.Faults = parseFaults(lFaults)
 
 		var parsedFlags []string
 		cmd.Flags().Visit(func(f *pflag.Flag) {
@@ -49,6 +71,35 @@ func init() {
 	localCmd.Flags().BoolVarP(&lConfig.TLS.Auto, "auto-tls", "", false, "Start with embedded certificate (default is false)")
 	localCmd.Flags().StringVarP(&lConfig.TLS.CertFile, "cert-file", "", "", "Path to certificate (default is empty)")
 	localCmd.Flags().StringVarP(&lConfig.TLS.KeyFile, "key-file", "", "", "Path to key")
+	localCmd.Flags().BoolVarP(&lConfig.WebDAV, "webdav", "", false, "Serve the path over WebDAV (default is false)")
+	localCmd.Flags().BoolVarP(&lConfig.ReadOnly, "read-only", "", false, "Reject write operations (default is false)")
+	localCmd.Flags().BoolVarP(&lConfig.NoListing, "no-listing", "", false, "Disable directory listings (default is false)")
+	localCmd.Flags().StringVarP(&lConfig.GitRef, "git-ref", "", "", "Serve this git commit, branch or tag instead of the files on disk (default is empty)")
+	localCmd.Flags().BoolVarP(&lConfig.Upload, "upload", "", false, "Allow uploading files from directory listings (default is false)")
+	localCmd.Flags().Int64VarP(&lConfig.UploadMaxSize, "upload-max-size", "", 100<<20, "Maximum size in bytes of uploads, 0 is unlimited")
+	localCmd.Flags().StringSliceVarP(&lConfig.Ignore, "ignore", "", nil, "Gitignore-style patterns of files not served (default is empty)")
+	localCmd.Flags().Int64VarP(&lConfig.ArchiveMaxSize, "archive-max-size", "", 0, "Maximum size in bytes of directory archives (default is unlimited)")
+	localCmd.Flags().Int64VarP(&lConfig.SearchMaxSize, "search-max-size", "", 1<<20, "Maximum size in bytes of files searched by content")
+	localCmd.Flags().BoolVarP(&lConfig.Compress, "compress", "", false, "Compress responses with brotli, zstd or gzip (default is false)")
+	localCmd.Flags().Int64VarP(&lConfig.CompressMinSize, "compress-min-size", "", 1024, "Minimum size in bytes of compressed responses")
+	localCmd.Flags().BoolVarP(&lConfig.SPA, "spa", "", false, "Serve the fallback file for unknown paths (default is false)")
+	localCmd.Flags().StringVarP(&lConfig.SPAFallback, "spa-fallback", "", "/index.html", "Fallback file of single-page applications")
+	localCmd.Flags().StringSliceVarP(&lConfig.SPAExclude, "spa-exclude", "", nil, "Paths or glob patterns without fallback (default is empty)")
+	localCmd.Flags().BoolVarP(&lConfig.LiveReload, "live-reload", "", false, "Reload pages when files change (default is false)")
+	localCmd.Flags().BoolVarP(&lConfig.ShowHidden, "show-hidden", "", false, "Serve hidden files (default is false)")
+	localCmd.Flags().StringVarP(&lConfig.ListingTemplate, "listing-template", "", "", "Path to the directory listing template (default is empty)")
+	localCmd.Flags().StringVarP(&lConfig.Theme, "theme", "", "", "Path to the directory with listing template and error pages (default is empty)")
+	localCmd.Flags().StringVarP(&lConfig.ETag, "etag", "", server.ETagWeak, "ETag of files: weak (size and modification time), strong (content hash) or off")
+	localCmd.Flags().StringVarP(&lConfig.Symlinks, "symlinks", "", server.SymlinksRoot, "Symbolic links followed: follow (all), root (only to files inside the path) or deny")
+	localCmd.Flags().StringVarP(&lConfig.Throttle, "throttle", "", "", "Simulate a network: slow-3g, 3g, 4g, dsl or wifi (default is off)")
+	localCmd.Flags().Int64VarP(&lConfig.ThrottleDown, "throttle-down", "", 0, "Download rate in kbit/s, replacing the one of the network (default is unlimited)")
+	localCmd.Flags().Int64VarP(&lConfig.ThrottleUp, "throttle-up", "", 0, "Upload rate in kbit/s, replacing the one of the network (default is unlimited)")
+	localCmd.Flags().DurationVarP(&lConfig.Latency, "latency", "", 0, "Latency added to each request, replacing the one of the network (default is none)")
+	localCmd.Flags().BoolVarP(&lConfig.ThrottleGlobal, "throttle-global", "", false, "Share the rates among all the connections (default is per connection)")
+	localCmd.Flags().StringArrayVarP(&lFaults, "fault", "", nil, "Fault injection rule \"[METHOD] [pattern] action [percentage]\", can be repeated (default is empty)")
+	localCmd.Flags().StringArrayVarP(&lHeaders, "header", "", nil, "Response header rule \"[pattern ]Name: value\", can be repeated (default is empty)")
+	localCmd.Flags().StringArrayVarP(&lRedirects, "redirect", "", nil, "Redirect or rewrite rule \"/from /to [status][!]\", can be repeated (default is empty)")
+	localCmd.Flags().StringArrayVarP(&lVHosts, "vhost", "", nil, "Virtual host \"host=path[:prefix[:options]]\" or \"host=url\", can be repeated (default is empty)")
 	localCmd.MarkFlagsRequiredTogether("cert-file", "key-file")
 	localCmd.MarkFlagsMutuallyExclusive("auto-tls", "cert-file")
 	localCmd.MarkFlagsMutuallyExclusive("expose", "cors", "auto-tls", "cert-file", "key-file")
@@ -0,0 +1,81 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package command
+
+import (
+	"fmt"
+	"github.com/charmbracelet/log"
+	"github.com/planta7/servant/internal/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"os"
+	"strconv"
+)
+
+var (
+	mConfig  = &server.Configuration{}
+	mHeaders []string
+	mFaults  []string
+)
+
+var mockCmd = &cobra.Command{
+	Use:     "mock [path]",
+	Aliases: []string{"m"},
+	Short:   "Start a mock API server from a directory of fixtures",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mConfig.Type = server.TypeMock
+		mConfig.Path = "./"
+		if len(args) > 0 {
+			mConfig.Path = args[0]
+		}
+		if info, err := os.Stat(mConfig.Path); err != nil {
+			log.Fatal("Invalid path", "error", err)
+		} else if !info.IsDir() {
+			log.Fatal("Invalid path", "error", fmt.Sprintf("%s is not a directory", mConfig.Path))
+		}
+		if err := server.CheckSymlinkPolicy(mConfig.Symlinks); err != nil {
+			log.Fatal("Invalid symlinks", "error", err)
+		}
+		mConfig.Headers = parseHeaders(mHeaders)
+		mConfig.Faults = parseFaults(mFaults)
+
+		var parsedFlags []string
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			if f.Name == "disable-tui" {
+				mConfig.DisableTUI, _ = strconv.ParseBool(f.Value.String())
+			}
+			parsedFlags = append(parsedFlags, fmt.Sprintf("%s:%s", f.Name, f.Value.String()))
+		})
+		log.Debug("Parameters", "args", args, "flags", parsedFlags)
+
+		servant := server.New(*mConfig)
+		servant.Start()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockCmd)
+	mockCmd.Flags().StringVarP(&mConfig.Host, "host", "", "", "Server host (default is empty)")
+	mockCmd.Flags().IntVarP(&mConfig.Port, "port", "p", 0, "Listen on port (default is random)")
+	mockCmd.Flags().BoolVarP(&mConfig.Expose, "expose", "e", false, "Expose through localtunnel (default is false)")
+	mockCmd.Flags().StringVarP(&mConfig.Subdomain, "subdomain", "s", "", "Subdomain (default is random)")
+	mockCmd.Flags().BoolVarP(&mConfig.CORS, "cors", "c", false, "Enable CORS (default is false)")
+	mockCmd.Flags().BoolVarP(&mConfig.Launch, "launch", "l", false, "Launch default browser (default is false)")
+	mockCmd.Flags().StringVarP(&mConfig.Auth, "auth", "", "", "username:password for basic auth (default is empty)")
+	mockCmd.Flags().BoolVarP(&mConfig.TLS.Auto, "auto-tls", "", false, "Start with embedded certificate (default is false)")
+	mockCmd.Flags().StringVarP(&mConfig.TLS.CertFile, "cert-file", "", "", "Path to certificate (default is empty)")
+	mockCmd.Flags().StringVarP(&mConfig.TLS.KeyFile, "key-file", "", "", "Path to key")
+	mockCmd.Flags().StringVarP(&mConfig.Symlinks, "symlinks", "", server.SymlinksRoot, "Symbolic links followed: follow (all), root (only to files inside the path) or deny")
+	mockCmd.Flags().StringVarP(&mConfig.Throttle, "throttle", "", "", "Simulate a network: slow-3g, 3g, 4g, dsl or wifi (default is off)")
+	mockCmd.Flags().Int64VarP(&mConfig.ThrottleDown, "throttle-down", "", 0, "Download rate in kbit/s, replacing the one of the network (default is unlimited)")
+	mockCmd.Flags().Int64VarP(&mConfig.ThrottleUp, "throttle-up", "", 0, "Upload rate in kbit/s, replacing the one of the network (default is unlimited)")
+	mockCmd.Flags().DurationVarP(&mConfig.Latency, "latency", "", 0, "Latency added to each request, replacing the one of the network (default is none)")
+	mockCmd.Flags().BoolVarP(&mConfig.ThrottleGlobal, "throttle-global", "", false, "Share the rates among all the connections (default is per connection)")
+	mockCmd.Flags().StringArrayVarP(&mFaults, "fault", "", nil, "Fault injection rule \"[METHOD] [pattern] action [percentage]\", can be repeated (default is empty)")
+	mockCmd.Flags().StringArrayVarP(&mHeaders, "header", "", nil, "Response header rule \"[pattern ]Name: value\", can be repeated (default is empty)")
+	mockCmd.MarkFlagsRequiredTogether("cert-file", "key-file")
+	mockCmd.MarkFlagsMutuallyExclusive("auto-tls", "cert-file")
+	mockCmd.MarkFlagsMutuallyExclusive("expose", "cors", "auto-tls", "cert-file", "key-file")
+}
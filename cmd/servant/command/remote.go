@@ -9,7 +9,12 @@ import (
 	"strconv"
 )
 
-var rConfig = &server.Configuration{}
+var (
+	rConfig    = &server.Configuration{}
+	rHeaders   []string
+	rRedirects []string
+	rFaults    []string
+)
 
 var remoteCmd = &cobra.Command{
 	Use:     "remote port",
@@ -18,6 +23,9 @@ var remoteCmd = &cobra.Command{
 	Args:    cobra.MaximumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		rConfig.Type = server.TypeRemote
+		rConfig.Headers = parseHeaders(rHeaders)
+		rConfig.Redirects = parseRedirects(rRedirects)
+		rConfig.Faults = parseFaults(rFaults)
 
 		var parsedFlags []string
 		cmd.Flags().Visit(func(f *pflag.Flag) {
@@ -38,5 +46,13 @@ func init() {
 	rootCmd.AddCommand(remoteCmd)
 	remoteCmd.Flags().StringVarP(&rConfig.Subdomain, "subdomain", "s", "", "Subdomain (default is random)")
 	remoteCmd.Flags().IntVarP(&rConfig.Port, "port", "p", 0, "Port to expose")
+	remoteCmd.Flags().StringArrayVarP(&rHeaders, "header", "", nil, "Response header rule \"[pattern ]Name: value\", can be repeated (default is empty)")
+	remoteCmd.Flags().StringArrayVarP(&rRedirects, "redirect", "", nil, "Redirect or rewrite rule \"/from /to [status][!]\", can be repeated (default is empty)")
+	remoteCmd.Flags().StringVarP(&rConfig.Throttle, "throttle", "", "", "Simulate a network: slow-3g, 3g, 4g, dsl or wifi (default is off)")
+	remoteCmd.Flags().Int64VarP(&rConfig.ThrottleDown, "throttle-down", "", 0, "Download rate in kbit/s, replacing the one of the network (default is unlimited)")
+	remoteCmd.Flags().Int64VarP(&rConfig.ThrottleUp, "throttle-up", "", 0, "Upload rate in kbit/s, replacing the one of the network (default is unlimited)")
+	remoteCmd.Flags().DurationVarP(&rConfig.Latency, "latency", "", 0, "Latency added to each request, replacing the one of the network (default is none)")
+	remoteCmd.Flags().BoolVarP(&rConfig.ThrottleGlobal, "throttle-global", "", false, "Share the rates among all the connections (default is per connection)")
+	remoteCmd.Flags().StringArrayVarP(&rFaults, "fault", "", nil, "Fault injection rule \"[METHOD] [pattern] action [percentage]\", can be repeated (default is empty)")
 	_ = remoteCmd.MarkFlagRequired("port")
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/planta7/servant/internal"
+	"github.com/planta7/servant/internal/server"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -79,8 +80,42 @@ func initConfig() {
 func bindFlags(cmd *cobra.Command) {
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
 		if !f.Changed && viper.IsSet(f.Name) {
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				_ = sv.Replace(viper.GetStringSlice(f.Name))
+				return
+			}
 			val := viper.Get(f.Name)
 			_ = cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
 		}
 	})
 }
+
+// parseHeaders parses the header rules given with --header, either as flags
+// or as a list in the configuration file.
+func parseHeaders(specs []string) []server.HeaderRule {
+	rules, err := server.ParseHeaderRules(specs)
+	if err != nil {
+		log.Fatal("Invalid header", "error", err)
+	}
+	return rules
+}
+
+// parseRedirects parses the redirect and rewrite rules given with
+// --redirect, either as flags or as a list in the configuration file.
+func parseRedirects(specs []string) []server.RedirectRule {
+	rules, err := server.ParseRedirectRules(specs)
+	if err != nil {
+		log.Fatal("Invalid redirect", "error", err)
+	}
+	return rules
+}
+
+// parseFaults parses the fault injection rules given with --fault, either
+// as flags or as a list in the configuration file.
+func parseFaults(specs []string) []server.FaultRule {
+	rules, err := server.ParseFaultRules(specs)
+	if err != nil {
+		log.Fatal("Invalid fault", "error", err)
+	}
+	return rules
+}
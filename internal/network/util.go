@@ -30,6 +30,12 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap gives http.ResponseController access to the underlying writer,
+// so streaming responses can still be flushed.
+func (lrw *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 func LocalIP() (net.IP, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
@@ -0,0 +1,226 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	archiveParam = "archive"
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+// errSkipDir is returned by a walkFunc to skip the contents of a directory.
+var errSkipDir = errors.New("skip this directory")
+
+type walkFunc func(name string, info fs.FileInfo) error
+
+// walkFS walks the tree rooted at name in sorted order, calling fn for each
+// file or directory (root included), until ctx is canceled. name is
+// '/'-separated. Links to directories are left out, as they may lead to
+// cycles.
+func walkFS(ctx context.Context, root http.FileSystem, name string, fn walkFunc) error {
+	return walkTree(ctx, root, name, false, fn)
+}
+
+func walkTree(ctx context.Context, root http.FileSystem, name string, link bool, fn walkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil || link && info.IsDir() {
+		_ = f.Close()
+		return err
+	}
+	if err = fn(name, info); err != nil || !info.IsDir() {
+		_ = f.Close()
+		if errors.Is(err, errSkipDir) {
+			return nil
+		}
+		return err
+	}
+	list, err := f.Readdir(-1)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	for _, child := range list {
+		link := child.Mode()&fs.ModeSymlink != 0
+		if err = walkTree(ctx, root, path.Join(name, child.Name()), link, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type archiveEntry struct {
+	name string // relative to the archived directory
+	path string // relative to the root
+	info fs.FileInfo
+}
+
+// serveArchive streams the directory name as a zip or tar.gz archive.
+// Entries are read as they are written, so nothing is buffered on disk.
+func (fh *fileHandler) serveArchive(w http.ResponseWriter, r *http.Request, name string, d fs.FileInfo, format string) {
+	var contentType, ext string
+	switch format {
+	case archiveZip:
+		contentType, ext = "application/zip", ".zip"
+	case archiveTarGz, "tgz":
+		contentType, ext = "application/gzip", ".tar.gz"
+	default:
+		fh.writeError(w, r, "unsupported archive format", http.StatusBadRequest)
+		return
+	}
+
+	entries, size, err := fh.archiveEntries(r.Context(), name)
+	if err != nil {
+		logf(r, "http: error reading directory: %v", err)
+		fh.serveError(w, r, err)
+		return
+	}
+	if fh.config.ArchiveMaxSize > 0 && size > fh.config.ArchiveMaxSize {
+		fh.writeError(w, r, fmt.Sprintf("archive exceeds the size limit (%s)", humanizeSize(fh.config.ArchiveMaxSize)),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fh.archiveName(d)+ext))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if contentType == "application/zip" {
+		err = fh.writeZip(w, entries)
+	} else {
+		err = fh.writeTarGz(w, entries)
+	}
+	if err != nil {
+		// Headers are already sent, the best we can do is to cut the stream
+		log.Warn("Error writing archive", "path", name, "error", err)
+	}
+}
+
+// archiveEntries collects the regular files and directories below name,
+// together with the total size of the files.
+func (fh *fileHandler) archiveEntries(ctx context.Context, name string) ([]archiveEntry, int64, error) {
+	var entries []archiveEntry
+	var size int64
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	err := walkFS(ctx, fh.root, name, func(p string, info fs.FileInfo) error {
+		if p == name {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		entries = append(entries, archiveEntry{
+			name: strings.TrimPrefix(p, prefix),
+			path: p,
+			info: info,
+		})
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return entries, size, err
+}
+
+func (fh *fileHandler) archiveName(d fs.FileInfo) string {
+	if d.Name() != "." && d.Name() != "/" {
+		return d.Name()
+	}
+	if abs, err := filepath.Abs(fh.config.Path); err == nil && filepath.Base(abs) != string(filepath.Separator) {
+		base := filepath.Base(abs)
+		return base[:len(base)-len(archiveExtension(base))]
+	}
+	return "servant"
+}
+
+func (fh *fileHandler) writeZip(w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		header, err := zip.FileInfoHeader(e.info)
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if e.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		ew, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if !e.info.IsDir() {
+			if err = fh.copyFile(ew, e); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+func (fh *fileHandler) writeTarGz(w io.Writer, entries []archiveEntry) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		header, err := tar.FileInfoHeader(e.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if e.info.IsDir() {
+			header.Name += "/"
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !e.info.IsDir() {
+			if err = fh.copyFile(tw, e); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyFile writes exactly the size collected while walking, so the archive
+// stays consistent even if the file changes in the meantime.
+func (fh *fileHandler) copyFile(w io.Writer, e archiveEntry) error {
+	f, err := fh.root.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(w, f, e.info.Size())
+	return err
+}
@@ -0,0 +1,114 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"docs/index.md":       "# docs",
+		"docs/guide/intro.md": "# intro",
+		"docs/.env":           "SECRET=1",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+	// Links to directories are left out, as these ones are cycles
+	_ = os.Symlink(".", filepath.Join(dir, "docs", "loop"))
+	_ = os.Symlink("..", filepath.Join(dir, "docs", "guide", "up"))
+	expected := map[string]string{"guide/": "", "guide/intro.md": "# intro", "index.md": "# docs"}
+
+	readZip := func(t *testing.T, body []byte) map[string]string {
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		assert.NoError(t, err)
+		entries := map[string]string{}
+		for _, zf := range zr.File {
+			rc, err := zf.Open()
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			entries[zf.Name] = string(content)
+		}
+		return entries
+	}
+	readTarGz := func(t *testing.T, body []byte) map[string]string {
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		tr := tar.NewReader(gr)
+		entries := map[string]string{}
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(tr)
+			entries[header.Name] = string(content)
+		}
+		return entries
+	}
+
+	tt := []struct {
+		name        string
+		method      string
+		target      string
+		maxSize     int64
+		status      int
+		disposition string
+		read        func(t *testing.T, body []byte) map[string]string
+	}{
+		{"zip", http.MethodGet, "/docs/?archive=zip", 0, http.StatusOK, `attachment; filename="docs.zip"`, readZip},
+		{"tar.gz", http.MethodGet, "/docs/?archive=tar.gz", 0, http.StatusOK, `attachment; filename="docs.tar.gz"`, readTarGz},
+		{"tgz", http.MethodGet, "/docs/?archive=tgz", 0, http.StatusOK, `attachment; filename="docs.tar.gz"`, readTarGz},
+		{"head", http.MethodHead, "/docs/?archive=zip", 0, http.StatusOK, `attachment; filename="docs.zip"`, nil},
+		{"within the size limit", http.MethodGet, "/docs/?archive=zip", 13, http.StatusOK, `attachment; filename="docs.zip"`, readZip},
+		{"over the size limit", http.MethodGet, "/docs/?archive=zip", 12, http.StatusRequestEntityTooLarge, "", nil},
+		{"unsupported format", http.MethodGet, "/docs/?archive=rar", 0, http.StatusBadRequest, "", nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := FileServer(http.Dir(dir), Configuration{Path: dir, ArchiveMaxSize: tc.maxSize})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.disposition, w.Header().Get("Content-Disposition"))
+			if tc.read == nil {
+				if tc.method == http.MethodHead {
+					assert.Zero(t, w.Body.Len())
+				}
+				return
+			}
+			// Hidden files are left out
+			assert.Equal(t, expected, tc.read(t, w.Body.Bytes()))
+		})
+	}
+}
+
+func TestArchiveEntriesCanceled(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	fh := FileServer(http.Dir(dir), Configuration{Path: dir}).(*fileHandler)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := fh.archiveEntries(ctx, "/")
+	assert.ErrorIs(t, err, context.Canceled)
+}
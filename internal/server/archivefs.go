@@ -0,0 +1,258 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// archiveExtensions are the archives that can be served as a directory.
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// archiveExtension returns the archive extension of name, if any.
+func archiveExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// openArchiveFS returns the tree of the archive file. The archive is read
+// once to build the tree, and the entries are read from the file when
+// opened: stored zip entries and plain tar ones as sections of the file,
+// and compressed ones by decompressing them again, so no content is kept
+// in memory.
+func openArchiveFS(file string) (fs.FS, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if archiveExtension(file) == ".zip" {
+		return openZipFS(file, info)
+	}
+	return openTarFS(file, info)
+}
+
+func openZipFS(file string, info fs.FileInfo) (fs.FS, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	// The file stays open to read the entries while serving
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	tree := newTreeFS(info.ModTime())
+	for _, zf := range zr.File {
+		zf := zf
+		if zf.FileInfo().IsDir() {
+			tree.addDir(zf.Name, zf.Modified)
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		size := int64(zf.UncompressedSize64)
+		tree.addFile(zf.Name, zf.Modified, size, func() (io.ReadSeeker, error) {
+			if zf.Method == zip.Store {
+				offset, err := zf.DataOffset()
+				if err != nil {
+					return nil, err
+				}
+				return io.NewSectionReader(f, offset, size), nil
+			}
+			return &streamReader{open: zf.Open, size: size}, nil
+		})
+	}
+	return tree, nil
+}
+
+func openTarFS(file string, info fs.FileInfo) (fs.FS, error) {
+	compressed := false
+	if ext := archiveExtension(file); ext == ".tar.gz" || ext == ".tgz" {
+		compressed = true
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	tr, closeTar, err := newTarReader(f, compressed)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	tree := newTreeFS(info.ModTime())
+	for index := 0; ; index++ {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = closeTar()
+			return nil, err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			tree.addDir(header.Name, header.ModTime)
+		case tar.TypeReg:
+			index, size := index, header.Size
+			if !compressed && !isSparse(header) {
+				// The tar reader seeks over the content of the entries,
+				// so the file is at the start of this one
+				offset, err := f.Seek(0, io.SeekCurrent)
+				if err != nil {
+					_ = closeTar()
+					return nil, err
+				}
+				tree.addFile(header.Name, header.ModTime, size, func() (io.ReadSeeker, error) {
+					return io.NewSectionReader(f, offset, size), nil
+				})
+				continue
+			}
+			tree.addFile(header.Name, header.ModTime, size, func() (io.ReadSeeker, error) {
+				return &streamReader{open: func() (io.ReadCloser, error) { return openTarEntry(file, compressed, index) }, size: size}, nil
+			})
+		}
+	}
+	if compressed {
+		return tree, closeTar()
+	}
+	// The file stays open to read the entries while serving
+	return tree, nil
+}
+
+// newTarReader returns the tar reader of f, and the function closing both.
+func newTarReader(f *os.File, compressed bool) (*tar.Reader, func() error, error) {
+	if !compressed {
+		return tar.NewReader(f), f.Close, nil
+	}
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tar.NewReader(gr), func() error {
+		_ = gr.Close()
+		return f.Close()
+	}, nil
+}
+
+// openTarEntry reads the archive file again up to the entry number index,
+// and returns its content.
+func openTarEntry(file string, compressed bool, index int) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	tr, closeTar, err := newTarReader(f, compressed)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	for i := 0; i <= index; i++ {
+		if _, err := tr.Next(); err != nil {
+			_ = closeTar()
+			if errors.Is(err, io.EOF) {
+				err = fs.ErrNotExist
+			}
+			return nil, err
+		}
+	}
+	return readCloser{Reader: tr, close: closeTar}, nil
+}
+
+// isSparse reports whether the content of the entry is stored in fragments
+// in the archive.
+func isSparse(header *tar.Header) bool {
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error {
+	return rc.close()
+}
+
+// streamReader makes a stream of size bytes seekable, opening it again to
+// seek backwards and discarding its data to seek forward. Seeking is lazy,
+// so finding the size or the start of a range doesn't read anything.
+type streamReader struct {
+	open   func() (io.ReadCloser, error)
+	size   int64
+	rc     io.ReadCloser
+	pos    int64
+	offset int64
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.rc == nil || s.offset < s.pos {
+		if s.rc != nil {
+			_ = s.rc.Close()
+		}
+		rc, err := s.open()
+		if err != nil {
+			s.rc = nil
+			return 0, err
+		}
+		s.rc, s.pos = rc, 0
+	}
+	if s.offset > s.pos {
+		n, err := io.CopyN(io.Discard, s.rc, s.offset-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.rc.Read(p)
+	s.pos += int64(n)
+	s.offset = s.pos
+	return n, err
+}
+
+func (s *streamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *streamReader) Close() error {
+	if s.rc == nil {
+		return nil
+	}
+	return s.rc.Close()
+}
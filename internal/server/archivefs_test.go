@@ -0,0 +1,150 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var archiveFiles = map[string]string{
+	"index.html":    "<html>home</html>",
+	"docs/big.txt":  strings.Repeat("0123456789", 1000),
+	"docs/small.md": "# docs",
+}
+
+func writeZip(t *testing.T, file string) {
+	f, err := os.Create(file)
+	assert.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range archiveFiles {
+		method := zip.Deflate
+		if name == "docs/small.md" {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+}
+
+func writeTar(t *testing.T, file string, compressed bool) {
+	f, err := os.Create(file)
+	assert.NoError(t, err)
+	defer f.Close()
+	var w io.Writer = f
+	if compressed {
+		gw := gzip.NewWriter(f)
+		defer gw.Close()
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: time.Now()}))
+	for name, content := range archiveFiles {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content)), ModTime: time.Now()}))
+		_, err = io.WriteString(tw, content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+}
+
+func TestArchiveFileServer(t *testing.T) {
+	dir := t.TempDir()
+	big := archiveFiles["docs/big.txt"]
+	tt := []struct {
+		name  string
+		file  string
+		write func(file string)
+	}{
+		{"zip", "site.zip", func(file string) { writeZip(t, file) }},
+		{"tar", "site.tar", func(file string) { writeTar(t, file, false) }},
+		{"tar.gz", "site.tar.gz", func(file string) { writeTar(t, file, true) }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, tc.file)
+			tc.write(file)
+			handler := localFiles(Configuration{Path: file})
+
+			get := func(target, rangeHeader string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, target, nil)
+				if rangeHeader != "" {
+					r.Header.Set("Range", rangeHeader)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				return w
+			}
+
+			w := get("/docs/small.md?raw=1", "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, archiveFiles["docs/small.md"], w.Body.String())
+
+			w = get("/docs/big.txt", "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, big, w.Body.String())
+
+			w = get("/docs/big.txt", "bytes=5000-5009")
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, big[5000:5010], w.Body.String())
+
+			// Backwards after forwards, on a new request
+			w = get("/docs/big.txt", "bytes=12-15")
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, big[12:16], w.Body.String())
+
+			w = get("/docs/big.txt", "bytes=-3")
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, big[len(big)-3:], w.Body.String())
+		})
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	content := "0123456789"
+	opened := 0
+	sr := &streamReader{
+		open: func() (io.ReadCloser, error) {
+			opened++
+			return io.NopCloser(strings.NewReader(content)), nil
+		},
+		size: int64(len(content)),
+	}
+	end, err := sr.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), end)
+	assert.Equal(t, 0, opened)
+
+	buf := make([]byte, 3)
+	_, err = sr.Seek(6, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(sr, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "678", string(buf))
+
+	_, err = sr.Seek(-8, io.SeekCurrent)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(sr, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "123", string(buf))
+	assert.Equal(t, 2, opened)
+
+	_, err = sr.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+	assert.NoError(t, sr.Close())
+}
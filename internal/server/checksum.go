@@ -0,0 +1,126 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+const (
+	checksumParam = "checksum"
+	// sumsFile is the virtual file with the SHA-256 digests of the files of
+	// a directory, in the format of sha256sum.
+	sumsFile = "SHA256SUMS"
+)
+
+// sumsLineSize is the length of the line of the file name in a sums file.
+func sumsLineSize(name string) int64 {
+	return int64(hex.EncodedLen(sha256.Size) + len("  ") + len(name) + len("\n"))
+}
+
+// serveChecksum replies with the digest of the file name, as sha256sum,
+// sha512sum and md5sum print it.
+func (fh *fileHandler) serveChecksum(w http.ResponseWriter, r *http.Request, name string, d fs.FileInfo, algorithm string) {
+	if _, ok := digestAlgorithms[algorithm]; !ok {
+		fh.writeError(w, r, "unsupported checksum algorithm, use sha256, sha512 or md5", http.StatusBadRequest)
+		return
+	}
+	sum, err := fh.digest(name, d, algorithm)
+	if err != nil {
+		logf(r, "http: error computing checksum: %v", err)
+		fh.serveError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	wrapFprintf(w, "%s  %s\n", hex.EncodeToString(sum), d.Name())
+}
+
+// isRegular reports whether mode is the one of a regular file, or of a link
+// to one, as links are listed with the mode of their target. Sums files
+// only have these files.
+func isRegular(mode fs.FileMode) bool {
+	return (mode &^ fs.ModeSymlink).IsRegular()
+}
+
+// addSums adds the virtual sums file to a listing with files, unless the
+// directory already has a real one. Its size is known without hashing them.
+func (d *listingData) addSums() {
+	entry := listingEntry{Name: sumsFile, URL: sumsFile, Type: "F", regular: true}
+	for _, e := range d.Entries {
+		if e.Name == sumsFile {
+			return
+		}
+		if !e.regular {
+			continue
+		}
+		entry.Size += sumsLineSize(e.Name)
+		if e.Modified.After(entry.Modified) {
+			entry.Modified = e.Modified
+		}
+	}
+	if entry.Size == 0 {
+		return
+	}
+	d.Entries = append(d.Entries, entry)
+	d.sortEntries()
+}
+
+// serveSums serves the virtual sums file of the directory dir. It reports
+// false if dir isn't a directory with files, so the caller can reply 404.
+func (fh *fileHandler) serveSums(w http.ResponseWriter, r *http.Request, dir string) bool {
+	f, err := fh.root.Open(dir)
+	if err != nil {
+		return false
+	}
+	list, err := f.Readdir(-1)
+	_ = f.Close()
+	if err != nil {
+		return false
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+
+	var buf bytes.Buffer
+	var modTime time.Time
+	for _, info := range list {
+		if !isRegular(info.Mode()) {
+			continue
+		}
+		name := path.Join(dir, info.Name())
+		sum, err := fh.digest(name, info, digestSHA256)
+		if err != nil {
+			logf(r, "http: error computing checksum: %v", err)
+			fh.serveError(w, r, err)
+			return true
+		}
+		wrapFprintf(&buf, "%s  %s\n", hex.EncodeToString(sum), info.Name())
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if buf.Len() == 0 {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	content := bytes.NewReader(buf.Bytes())
+	sizeFunc := func() (int64, error) { return content.Size(), nil }
+	fh.serveContent(w, r, sumsFile, modTime, sizeFunc, content)
+	return true
+}
+
+func (fh *fileHandler) stat(name string) (fs.FileInfo, error) {
+	f, err := fh.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
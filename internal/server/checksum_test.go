@@ -0,0 +1,102 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeChecksum(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("servant")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), content, 0o644))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+	sha256sum, sha512sum, md5sum := sha256.Sum256(content), sha512.Sum512(content), md5.Sum(content)
+
+	tt := []struct {
+		name     string
+		url      string
+		status   int
+		expected string
+	}{
+		{"sha256", "/file.txt?checksum=sha256", http.StatusOK, hex.EncodeToString(sha256sum[:]) + "  file.txt\n"},
+		{"sha512", "/file.txt?checksum=sha512", http.StatusOK, hex.EncodeToString(sha512sum[:]) + "  file.txt\n"},
+		{"md5", "/file.txt?checksum=md5", http.StatusOK, hex.EncodeToString(md5sum[:]) + "  file.txt\n"},
+		{"unknown algorithm", "/file.txt?checksum=crc32", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, tc.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeSums(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"b.txt": "bee", "a.txt": "ay", "sub/c.txt": "sea"}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+	hasLinks := os.Symlink("a.txt", filepath.Join(dir, "link.txt")) == nil &&
+		os.Symlink("sub", filepath.Join(dir, "linkdir")) == nil
+	if l, err := net.Listen("unix", filepath.Join(dir, "s.sock")); err == nil {
+		defer l.Close()
+	}
+	handler := FileServer(symlinkFileSystem{FileSystem: http.Dir(dir), policy: newSymlinkPolicy(dir, SymlinksRoot)}, Configuration{Path: dir})
+
+	line := func(name, content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	}
+	expected := line("a.txt", "ay") + line("b.txt", "bee")
+	if hasLinks {
+		expected += line("link.txt", "ay")
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/SHA256SUMS", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, expected, w.Body.String())
+
+	// The listing advertises the size of the served file
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?format=json", nil))
+	var listing listingData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	found := false
+	for _, e := range listing.Entries {
+		if e.Name == sumsFile {
+			found = true
+			assert.Equal(t, int64(len(expected)), e.Size)
+		}
+	}
+	assert.True(t, found)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sub/SHA256SUMS", nil))
+	assert.Equal(t, line("c.txt", "sea"), w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing/SHA256SUMS", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,182 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+)
+
+// supportedEncodings is sorted by preference, used to break ties between
+// encodings with the same quality value.
+var supportedEncodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+
+var compressibleTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/wasm",
+	"application/xhtml+xml",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// isCompressible reports whether a response of the given Content-Type
+// is worth compressing. Media and archives are already compressed.
+func isCompressible(ctype string) bool {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, t := range compressibleTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the encoding among the offered ones that best
+// matches the Accept-Encoding header of r, or "" for the identity encoding.
+// See https://www.rfc-editor.org/rfc/rfc9110#section-12.5.3.
+func negotiateEncoding(r *http.Request, offered []string) string {
+	if accepted := acceptedEncodings(r, offered); len(accepted) > 0 {
+		return accepted[0]
+	}
+	return ""
+}
+
+// acceptedEncodings returns the offered encodings accepted by r, the best
+// match first. Ties keep the order of offered.
+func acceptedEncodings(r *http.Request, offered []string) []string {
+	accept := r.Header.Get("Accept-Encoding")
+	if accept == "" {
+		return nil
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(textproto.TrimString(coding))
+		q := 1.0
+		if k, v, ok := strings.Cut(textproto.TrimString(params), "="); ok && textproto.TrimString(k) == "q" {
+			if parsed, err := strconv.ParseFloat(textproto.TrimString(v), 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[coding] = q
+	}
+
+	var accepted []string
+	for _, coding := range offered {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			accepted = append(accepted, coding)
+			qualities[coding] = q
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return qualities[accepted[i]] > qualities[accepted[j]] })
+	return accepted
+}
+
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(textproto.TrimString(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// newEncoder wraps w with a compressor for the given encoding. The returned
+// writer must be closed to flush the remaining data.
+func newEncoder(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case encodingBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case encodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	default:
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	}
+}
+
+// precompressedExtensions maps each encoding to the extension of the sidecar
+// files produced by build tools, e.g. app.js.br next to app.js.
+var precompressedExtensions = map[string]string{
+	encodingBrotli: ".br",
+	encodingZstd:   ".zst",
+	encodingGzip:   ".gz",
+}
+
+// servePrecompressed serves a precompressed sibling of the file name, if
+// there is one matching the Accept-Encoding of r, and reports whether it did.
+// Only the sidecars of accepted encodings are looked up, in order of
+// preference. Sidecars older than the original file are considered stale
+// and ignored. Validators are those of the original file.
+func (fh *fileHandler) servePrecompressed(w http.ResponseWriter, r *http.Request, name string, d fs.FileInfo) bool {
+	if w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+	if ctype == "" || fh.live != nil && strings.HasPrefix(ctype, "text/html") {
+		// pages must go through serveContent to get the live reload script
+		return false
+	}
+
+	for _, encoding := range acceptedEncodings(r, supportedEncodings) {
+		if fh.serveSidecar(w, r, name, d, ctype, encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveSidecar serves the sidecar of name for encoding, unless it is
+// missing or stale.
+func (fh *fileHandler) serveSidecar(w http.ResponseWriter, r *http.Request, name string, d fs.FileInfo, ctype, encoding string) bool {
+	sidecar, err := fh.root.Open(name + precompressedExtensions[encoding])
+	if err != nil {
+		return false
+	}
+	defer sidecar.Close()
+	info, err := sidecar.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(d.ModTime()) {
+		return false
+	}
+	addVary(w.Header(), "Accept-Encoding")
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Encoding", encoding)
+	if etag := w.Header().Get("Etag"); etag != "" {
+		w.Header().Set("Etag", encodedETag(etag, encoding))
+	}
+	sizeFunc := func() (int64, error) { return info.Size(), nil }
+	fh.serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, sidecar)
+	return true
+}
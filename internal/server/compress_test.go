@@ -0,0 +1,174 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tt := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{
+			"no header",
+			"",
+			"",
+		},
+		{
+			"single encoding",
+			"gzip",
+			encodingGzip,
+		},
+		{
+			"preferred on tie",
+			"gzip, deflate, br, zstd",
+			encodingBrotli,
+		},
+		{
+			"quality values",
+			"br;q=0.2, gzip;q=0.8",
+			encodingGzip,
+		},
+		{
+			"rejected encoding",
+			"br;q=0, gzip;q=0",
+			"",
+		},
+		{
+			"wildcard",
+			"*;q=0.5, br;q=0",
+			encodingZstd,
+		},
+		{
+			"unsupported",
+			"deflate, identity",
+			"",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept-Encoding", tc.accept)
+			}
+			res := negotiateEncoding(r, supportedEncodings)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestIsCompressible(t *testing.T) {
+	tt := []struct {
+		name     string
+		ctype    string
+		expected bool
+	}{
+		{
+			"html",
+			"text/html; charset=utf-8",
+			true,
+		},
+		{
+			"javascript",
+			"application/javascript",
+			true,
+		},
+		{
+			"json suffix",
+			"application/ld+json",
+			true,
+		},
+		{
+			"svg",
+			"image/svg+xml",
+			true,
+		},
+		{
+			"png",
+			"image/png",
+			false,
+		},
+		{
+			"zip",
+			"application/zip",
+			false,
+		},
+		{
+			"invalid",
+			"",
+			false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res := isCompressible(tc.ctype)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for name, content := range map[string]string{"app.js": "console.log(1)", "app.js.br": "brotli", "app.js.gz": "gzip"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+	stale := modTime.Add(-time.Minute)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "app.js.gz"), stale, stale))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+
+	tt := []struct {
+		name     string
+		accept   string
+		rangeHdr string
+		status   int
+		encoding string
+		expected string
+	}{
+		{"brotli", "gzip, br", "", http.StatusOK, "br", "brotli"},
+		{"stale sidecar is ignored", "gzip", "", http.StatusOK, "", "console.log(1)"},
+		{"stale sidecar falls back to the next", "gzip, br;q=0.5", "", http.StatusOK, "br", "brotli"},
+		{"no accept encoding", "", "", http.StatusOK, "", "console.log(1)"},
+		{"range", "br", "bytes=1-3", http.StatusPartialContent, "br", "rot"},
+		{"unsatisfiable range", "br", "bytes=100-200", http.StatusRequestedRangeNotSatisfiable, "", "invalid range: failed to overlap\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept-Encoding", tc.accept)
+			}
+			if tc.rangeHdr != "" {
+				r.Header.Set("Range", tc.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.encoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tc.expected, w.Body.String())
+			if tc.status == http.StatusRequestedRangeNotSatisfiable {
+				// The error is not the sidecar
+				assert.Empty(t, w.Header().Get("Etag"))
+				assert.Empty(t, w.Header().Get("Last-Modified"))
+				assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+				return
+			}
+			assert.Contains(t, w.Header().Get("Content-Type"), "javascript")
+			assert.Equal(t, modTime.UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"container/list"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// digestCacheSize bounds the number of digests kept in memory.
+const digestCacheSize = 4096
+
+const (
+	digestSHA256 = "sha256"
+	digestSHA512 = "sha512"
+	digestMD5    = "md5"
+)
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	digestSHA256: sha256.New,
+	digestSHA512: sha512.New,
+	digestMD5:    md5.New,
+}
+
+type digestKey struct {
+	name      string
+	algorithm string
+}
+
+type fileCacheEntry[K comparable] struct {
+	key     K
+	size    int64
+	modTime time.Time
+	data    []byte
+}
+
+// fileCache is a least recently used cache of data computed from files,
+// like their digests or thumbnails. Entries are tied to the size and
+// modification time of the file they were computed from, so any change to
+// the file invalidates them.
+type fileCache[K comparable] struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[K]*list.Element
+	order    *list.List
+}
+
+func newFileCache[K comparable](capacity int) *fileCache[K] {
+	return &fileCache[K]{
+		capacity: capacity,
+		entries:  map[K]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *fileCache[K]) get(key K, info fs.FileInfo) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*fileCacheEntry[K])
+	if entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.data, true
+}
+
+func (c *fileCache[K]) put(key K, info fs.FileInfo, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&fileCacheEntry[K]{
+		key:     key,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		data:    data,
+	})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*fileCacheEntry[K]).key)
+	}
+}
+
+// digest returns the digest of the file name, computed while streaming it
+// and cached as long as the file doesn't change. algorithm must be one of
+// digestAlgorithms.
+func (fh *fileHandler) digest(name string, info fs.FileInfo, algorithm string) ([]byte, error) {
+	key := digestKey{name: name, algorithm: algorithm}
+	if sum, ok := fh.digests.get(key, info); ok {
+		return sum, nil
+	}
+	f, err := fh.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := digestAlgorithms[algorithm]()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	sum := h.Sum(nil)
+	fh.digests.put(key, info, sum)
+	return sum, nil
+}
@@ -0,0 +1,84 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func fileInfo(t *testing.T, size int64, modTime time.Time) fs.FileInfo {
+	files := fstest.MapFS{"file": {Data: make([]byte, size), ModTime: modTime}}
+	info, err := fs.Stat(files, "file")
+	assert.NoError(t, err)
+	return info
+}
+
+func TestFileCache(t *testing.T) {
+	now := time.Now()
+	tt := []struct {
+		name     string
+		stored   fs.FileInfo
+		current  fs.FileInfo
+		key      digestKey
+		expected bool
+	}{
+		{
+			"unchanged file",
+			fileInfo(t, 10, now),
+			fileInfo(t, 10, now),
+			digestKey{"/file", digestSHA256},
+			true,
+		},
+		{
+			"size changed",
+			fileInfo(t, 10, now),
+			fileInfo(t, 11, now),
+			digestKey{"/file", digestSHA256},
+			false,
+		},
+		{
+			"modification time changed",
+			fileInfo(t, 10, now),
+			fileInfo(t, 10, now.Add(time.Second)),
+			digestKey{"/file", digestSHA256},
+			false,
+		},
+		{
+			"another file",
+			fileInfo(t, 10, now),
+			fileInfo(t, 10, now),
+			digestKey{"/other", digestSHA256},
+			false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := newFileCache[digestKey](2)
+			cache.put(digestKey{"/file", digestSHA256}, tc.stored, []byte{1})
+			_, ok := cache.get(tc.key, tc.current)
+			assert.Equal(t, tc.expected, ok)
+		})
+	}
+}
+
+func TestFileCacheEviction(t *testing.T) {
+	info := fileInfo(t, 1, time.Now())
+	cache := newFileCache[digestKey](2)
+	cache.put(digestKey{"/a", digestSHA256}, info, []byte{1})
+	cache.put(digestKey{"/b", digestSHA256}, info, []byte{2})
+	_, _ = cache.get(digestKey{"/a", digestSHA256}, info)
+	cache.put(digestKey{"/c", digestSHA256}, info, []byte{3})
+
+	_, ok := cache.get(digestKey{"/a", digestSHA256}, info)
+	assert.True(t, ok)
+	_, ok = cache.get(digestKey{"/b", digestSHA256}, info)
+	assert.False(t, ok)
+	_, ok = cache.get(digestKey{"/c", digestSHA256}, info)
+	assert.True(t, ok)
+}
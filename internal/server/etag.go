@@ -0,0 +1,75 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ETagWeak tags files by size, modification time and inode. It's cheap,
+	// but two copies of a file never share the tag.
+	ETagWeak = "weak"
+	// ETagStrong tags files by a hash of their content, computed once and
+	// cached until the file changes.
+	ETagStrong = "strong"
+	ETagOff    = "off"
+)
+
+// CheckETagMode reports an error if mode is not an ETag mode. File servers
+// without one use ETagWeak.
+func CheckETagMode(mode string) error {
+	switch mode {
+	case ETagWeak, ETagStrong, ETagOff:
+		return nil
+	default:
+		return fmt.Errorf("unknown ETag mode %q, use weak, strong or off", mode)
+	}
+}
+
+// setETag sets the ETag header of the file name, unless it is already set.
+func (fh *fileHandler) setETag(w http.ResponseWriter, name string, d fs.FileInfo) {
+	if w.Header().Get("Etag") != "" {
+		return
+	}
+	switch fh.config.ETag {
+	case ETagStrong:
+		sum, err := fh.digest(name, d, digestSHA256)
+		if err != nil {
+			log.Debug("Unable to hash file, skipping ETag", "file", name, "error", err)
+			return
+		}
+		w.Header().Set("Etag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	case ETagWeak:
+		w.Header().Set("Etag", fmt.Sprintf(`W/"%s-%s-%s"`,
+			strconv.FormatInt(d.Size(), 36),
+			strconv.FormatInt(d.ModTime().UnixNano(), 36),
+			strconv.FormatUint(inode(d), 36)))
+	}
+}
+
+// encodedETag returns the tag of an encoded representation of a file:
+// its own tag with the encoding appended, so the variants don't mix up.
+func encodedETag(etag string, encoding string) string {
+	if etag == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// weakETag returns etag as a weak tag. Bytes compressed on the fly may change
+// between library versions, so they are only semantically equivalent to the
+// file. See RFC 7232 section 2.1.
+func weakETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
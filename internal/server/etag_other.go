@@ -0,0 +1,12 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+//go:build !unix
+
+package server
+
+import "io/fs"
+
+func inode(_ fs.FileInfo) uint64 {
+	return 0
+}
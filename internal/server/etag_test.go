@@ -0,0 +1,69 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestServeETag(t *testing.T) {
+	const content = "hello, world"
+	sum := sha256.Sum256([]byte(content))
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"hello.txt": content})
+
+	tt := []struct {
+		name     string
+		mode     string
+		expected *regexp.Regexp
+	}{
+		{"default", "", regexp.MustCompile(`^W/"[0-9a-z]+-[0-9a-z]+-[0-9a-z]+"$`)},
+		{"weak", ETagWeak, regexp.MustCompile(`^W/"[0-9a-z]+-[0-9a-z]+-[0-9a-z]+"$`)},
+		{"strong", ETagStrong, regexp.MustCompile(`^"` + hex.EncodeToString(sum[:16]) + `"$`)},
+		{"off", ETagOff, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := FileServer(http.Dir(dir), Configuration{Path: dir, ETag: tc.mode})
+			serve := func(header, value string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, "/hello.txt", nil)
+				if header != "" {
+					r.Header.Set(header, value)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				return w
+			}
+
+			w := serve("", "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			etag := w.Header().Get("Etag")
+			if tc.expected == nil {
+				assert.Empty(t, etag)
+			} else {
+				assert.Regexp(t, tc.expected, etag)
+				// the tag is stable between requests
+				assert.Equal(t, etag, serve("", "").Header().Get("Etag"))
+
+				w = serve("If-None-Match", etag)
+				assert.Equal(t, http.StatusNotModified, w.Code)
+				assert.Empty(t, w.Body.String())
+				assert.Equal(t, http.StatusOK, serve("If-None-Match", `"other"`).Code)
+			}
+
+			w = serve("If-Match", `"other"`)
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+			if tc.mode == ETagStrong {
+				assert.Equal(t, http.StatusOK, serve("If-Match", etag).Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/planta7/servant/internal/network"
+	"github.com/planta7/servant/internal/tui"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	faultStatus   = "status="
+	faultLatency  = "latency="
+	faultReset    = "reset"
+	faultTruncate = "truncate"
+)
+
+var errTruncated = errors.New("response truncated by a fault")
+
+// FaultRule injects a fault in a share of the requests to Pattern, a
+// gitignore-style path pattern, with Method. Empty ones match every
+// request. Latency delays the response, which can also be replaced by an
+// error Status, the reset of the connection or a body cut in half.
+type FaultRule struct {
+	Method      string
+	Pattern     string
+	Probability float64
+	Status      int
+	Latency     time.Duration
+	Reset       bool
+	Truncate    bool
+	paths       *ignoreRules
+}
+
+// ParseFaultRules parses specs made of the action and, optionally, the
+// method, the path pattern and the percentage of requests, in any order:
+//
+//	[METHOD] [pattern] status=503|latency=2s|reset|truncate... [10%]
+func ParseFaultRules(specs []string) ([]FaultRule, error) {
+	var rules []FaultRule
+	for _, spec := range specs {
+		rule, err := parseFaultRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseFaultRule(spec string) (FaultRule, error) {
+	rule := FaultRule{Probability: 1}
+	hasAction := false
+	for _, field := range strings.Fields(spec) {
+		switch {
+		case strings.HasSuffix(field, "%"):
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+			if err != nil || percent < 0 || percent > 100 {
+				return FaultRule{}, fmt.Errorf("invalid percentage %q in fault %q", field, spec)
+			}
+			rule.Probability = percent / 100
+		case strings.HasPrefix(field, faultStatus):
+			status, err := strconv.Atoi(strings.TrimPrefix(field, faultStatus))
+			if err != nil || status < 100 || status > 599 {
+				return FaultRule{}, fmt.Errorf("invalid status %q in fault %q", field, spec)
+			}
+			rule.Status, hasAction = status, true
+		case strings.HasPrefix(field, faultLatency):
+			latency, err := time.ParseDuration(strings.TrimPrefix(field, faultLatency))
+			if err != nil || latency < 0 {
+				return FaultRule{}, fmt.Errorf("invalid latency %q in fault %q", field, spec)
+			}
+			rule.Latency, hasAction = latency, true
+		case field == faultReset:
+			rule.Reset, hasAction = true, true
+		case field == faultTruncate:
+			rule.Truncate, hasAction = true, true
+		case isMethod(field) && rule.Method == "":
+			rule.Method = field
+		case rule.Pattern == "":
+			if strings.HasPrefix(field, "!") || strings.HasPrefix(field, "#") {
+				return FaultRule{}, fmt.Errorf("invalid pattern %q in fault %q", field, spec)
+			}
+			rule.Pattern = field
+			rule.paths = newIgnoreRules([]string{field})
+			if len(rule.paths.patterns) == 0 {
+				return FaultRule{}, fmt.Errorf("invalid pattern %q in fault %q", field, spec)
+			}
+		default:
+			return FaultRule{}, fmt.Errorf("unexpected %q in fault %q", field, spec)
+		}
+	}
+	if !hasAction {
+		return FaultRule{}, fmt.Errorf("missing status=, latency=, reset or truncate in fault %q", spec)
+	}
+	return rule, nil
+}
+
+// isMethod reports whether field looks like an HTTP method.
+func isMethod(field string) bool {
+	return strings.IndexFunc(field, func(r rune) bool { return r < 'A' || r > 'Z' }) < 0
+}
+
+func (fr *FaultRule) matches(r *http.Request) bool {
+	if fr.Method != "" && fr.Method != r.Method {
+		return false
+	}
+	return fr.paths == nil || fr.paths.match(r.URL.Path, strings.HasSuffix(r.URL.Path, "/"))
+}
+
+// fault is the combination of the rules triggered by a request.
+type fault struct {
+	status   int
+	latency  time.Duration
+	reset    bool
+	truncate bool
+}
+
+// String describes the fault for the request list.
+func (f *fault) String() string {
+	var parts []string
+	if f.latency > 0 {
+		parts = append(parts, "+"+f.latency.String())
+	}
+	switch {
+	case f.reset:
+		parts = append(parts, faultReset)
+	case f.status != 0:
+		parts = append(parts, strconv.Itoa(f.status))
+	case f.truncate:
+		parts = append(parts, faultTruncate)
+	}
+	return strings.Join(parts, " ")
+}
+
+// faultInjector applies the fault rules while chaos mode is on.
+type faultInjector struct {
+	rules   []FaultRule
+	enabled atomic.Bool
+}
+
+func newFaultInjector(config Configuration) *faultInjector {
+	fi := &faultInjector{rules: config.Faults}
+	fi.enabled.Store(len(config.Faults) > 0)
+	return fi
+}
+
+// pick rolls the dice for the rules matching r, and returns the resulting
+// fault, or nil. Latencies add up, and the first status, reset or
+// truncation wins.
+func (fi *faultInjector) pick(r *http.Request) *fault {
+	if !fi.enabled.Load() {
+		return nil
+	}
+	var f *fault
+	for i := range fi.rules {
+		rule := &fi.rules[i]
+		if !rule.matches(r) || rand.Float64() >= rule.Probability {
+			continue
+		}
+		if f == nil {
+			f = &fault{}
+		}
+		f.latency += rule.Latency
+		if f.status == 0 && !f.reset && !f.truncate {
+			f.status, f.reset, f.truncate = rule.Status, rule.Reset, rule.Truncate
+		}
+	}
+	return f
+}
+
+func (fi *faultInjector) toggle() string {
+	if len(fi.rules) == 0 {
+		return "Chaos mode has no fault rules, add them with --fault"
+	}
+	enabled := !fi.enabled.Load()
+	fi.enabled.Store(enabled)
+	if enabled {
+		return fmt.Sprintf("Chaos mode on (%d fault rules)", len(fi.rules))
+	}
+	return "Chaos mode off"
+}
+
+func (fi *faultInjector) control() tui.Control {
+	return tui.Control{Key: "c", Help: "toggle chaos mode", Change: fi.toggle}
+}
+
+// sleep waits for d, or until the request is cancelled.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// resetConnection closes the connection of w without a response, with a
+// TCP reset if possible. It reports false if the connection can't be taken
+// over, as with HTTP/2, so the caller must abort the handler instead.
+func resetConnection(w http.ResponseWriter) bool {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return false
+	}
+	var raw net.Conn = conn
+	if tc, ok := raw.(*tls.Conn); ok {
+		raw = tc.NetConn()
+	}
+	if l, ok := raw.(interface{ SetLinger(sec int) error }); ok {
+		_ = l.SetLinger(0)
+	}
+	_ = conn.Close()
+	return true
+}
+
+// truncateWriter sends half of the body, as declared by Content-Length, or
+// of its first write if unknown, and fails the rest of writes.
+type truncateWriter struct {
+	http.ResponseWriter
+	remaining int64
+	truncated bool
+}
+
+func newTruncateWriter(w http.ResponseWriter) *truncateWriter {
+	return &truncateWriter{ResponseWriter: w, remaining: -1}
+}
+
+func (tw *truncateWriter) Write(b []byte) (int, error) {
+	if tw.remaining < 0 {
+		length, err := strconv.ParseInt(tw.Header().Get(network.ContentLength), 10, 64)
+		if err != nil {
+			length = int64(len(b))
+		}
+		tw.remaining = length / 2
+	}
+	if int64(len(b)) <= tw.remaining {
+		tw.remaining -= int64(len(b))
+		return tw.ResponseWriter.Write(b)
+	}
+	n, _ := tw.ResponseWriter.Write(b[:tw.remaining])
+	tw.remaining = 0
+	tw.truncated = true
+	return n, errTruncated
+}
+
+func (tw *truncateWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
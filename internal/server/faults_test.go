@@ -0,0 +1,104 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseFaultRules(t *testing.T) {
+	tt := []struct {
+		name     string
+		spec     string
+		expected FaultRule
+		wantErr  bool
+	}{
+		{
+			"status for a share of a path",
+			"/api/* status=503 10%",
+			FaultRule{Pattern: "/api/*", Probability: 0.1, Status: 503},
+			false,
+		},
+		{
+			"latency for a method",
+			"POST latency=2s",
+			FaultRule{Method: "POST", Probability: 1, Latency: 2 * time.Second},
+			false,
+		},
+		{
+			"several actions in any order",
+			"50% truncate GET latency=100ms *.zip",
+			FaultRule{Method: "GET", Pattern: "*.zip", Probability: 0.5, Latency: 100 * time.Millisecond, Truncate: true},
+			false,
+		},
+		{"reset", "reset", FaultRule{Probability: 1, Reset: true}, false},
+		{"missing action", "/api/* 10%", FaultRule{}, true},
+		{"invalid percentage", "reset 150%", FaultRule{}, true},
+		{"invalid status", "status=5000", FaultRule{}, true},
+		{"invalid latency", "latency=soon", FaultRule{}, true},
+		{"several patterns", "/a /b reset", FaultRule{}, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := parseFaultRule(tc.spec)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			rule.paths = nil
+			assert.Equal(t, tc.expected, rule)
+		})
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	rules, err := ParseFaultRules([]string{
+		"POST latency=1s",
+		"/api/** latency=2s",
+		"/api/** status=503",
+		"reset 0%",
+	})
+	assert.NoError(t, err)
+	fi := newFaultInjector(Configuration{Faults: rules})
+
+	tt := []struct {
+		name     string
+		method   string
+		target   string
+		expected *fault
+	}{
+		{"no match", http.MethodGet, "/index.html", nil},
+		{"latency", http.MethodPost, "/form", &fault{latency: time.Second}},
+		{"latencies add up", http.MethodPost, "/api/users", &fault{latency: 3 * time.Second, status: 503}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, fi.pick(httptest.NewRequest(tc.method, tc.target, nil)))
+		})
+	}
+
+	assert.Equal(t, "Chaos mode off", fi.toggle())
+	assert.Nil(t, fi.pick(httptest.NewRequest(http.MethodPost, "/form", nil)))
+}
+
+func TestTruncateWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	tw := newTruncateWriter(rec)
+	tw.Header().Set("Content-Length", "10")
+	n, err := tw.Write([]byte("abc"))
+	assert.Equal(t, 3, n)
+	assert.NoError(t, err)
+	n, err = tw.Write([]byte("defghij"))
+	assert.Equal(t, 2, n)
+	assert.ErrorIs(t, err, errTruncated)
+	assert.True(t, tw.truncated)
+	assert.Equal(t, "abcde", rec.Body.String())
+}
@@ -4,8 +4,10 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"io/fs"
 	"log"
@@ -13,10 +15,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
-	"net/url"
 	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +38,24 @@ const fileServerCss = `
 			margin-bottom: 40px;
 		}
 
+		.archives {
+			font-size: 14px;
+			margin-bottom: 30px;
+		}
+
+		.commit {
+			font-size: 14px;
+		}
+
+		#filter, #search {
+			margin-left: 20px;
+			padding: 4px 8px;
+		}
+
+		tr:first-child a {
+			text-decoration: none;
+		}
+
 		table {
 			border-collapse: collapse;
 		}
@@ -104,6 +122,7 @@ type anyDirs interface {
 	size(i int) int64
 	lastModified(i int) time.Time
 	isDir(i int) bool
+	isRegular(i int) bool
 	fType(i int) string
 }
 
@@ -114,7 +133,11 @@ func (d fileInfoDirs) isDir(i int) bool             { return d[i].IsDir() }
 func (d fileInfoDirs) name(i int) string            { return d[i].Name() }
 func (d fileInfoDirs) size(i int) int64             { return d[i].Size() }
 func (d fileInfoDirs) lastModified(i int) time.Time { return d[i].ModTime() }
+func (d fileInfoDirs) isRegular(i int) bool         { return isRegular(d[i].Mode()) }
 func (d fileInfoDirs) fType(i int) string {
+	if d[i].Mode()&fs.ModeSymlink != 0 {
+		return "L"
+	}
 	if d[i].IsDir() {
 		return "D"
 	}
@@ -136,7 +159,14 @@ func (d dirEntryDirs) size(i int) int64 {
 	return info.Size()
 }
 
+func (d dirEntryDirs) isRegular(i int) bool {
+	return isRegular(d[i].Type())
+}
+
 func (d dirEntryDirs) fType(i int) string {
+	if d[i].Type()&fs.ModeSymlink != 0 {
+		return "L"
+	}
 	if d[i].IsDir() {
 		return "D"
 	}
@@ -144,28 +174,89 @@ func (d dirEntryDirs) fType(i int) string {
 }
 
 type fileHandler struct {
-	root http.FileSystem
+	root      http.FileSystem
+	config    Configuration
+	ignore    *ignoreRules
+	redirects []RedirectRule
+	live      *liveReload
+	listing   *template.Template
+	digests   *fileCache[digestKey]
+	thumbs    *fileCache[string]
+	commit    *gitCommit
 }
 
 type Handler interface {
 	http.Handler
 }
 
-func FileServer(root http.FileSystem) Handler {
-	return &fileHandler{root}
+// FileServer serves root, which may be any http.FileSystem with seekable
+// files, like http.FS of an embed.FS. Uploads and live reload work on the
+// files of config.Path, so they must be disabled for other sources.
+func FileServer(root http.FileSystem, config Configuration) Handler {
+	if config.ETag == "" {
+		config.ETag = ETagWeak
+	}
+	ignore := loadIgnoreRules(root, config)
+	fh := &fileHandler{
+		root:      ignoreFileSystem{FileSystem: root, rules: ignore},
+		config:    config,
+		ignore:    ignore,
+		redirects: loadRedirectRules(root, config),
+		listing:   loadListingTemplate(config),
+		digests:   newFileCache[digestKey](digestCacheSize),
+		thumbs:    newFileCache[string](thumbCacheSize),
+	}
+	if git, ok := root.(gitFileSystem); ok {
+		fh.commit = git.commit
+	}
+	if config.LiveReload {
+		fh.enableLiveReload()
+	}
+	return fh
 }
 
-func (f *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (fh *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	upath := r.URL.Path
 	if !strings.HasPrefix(upath, "/") {
 		upath = "/" + upath
 		r.URL.Path = upath
 	}
-	serveFile(w, r, f.root, path.Clean(upath), true)
+	if fh.live != nil && upath == liveReloadPath {
+		fh.live.ServeHTTP(w, r)
+		return
+	}
+	if upath == searchPath {
+		fh.serveSearch(w, r)
+		return
+	}
+	if serveRedirects(w, r, fh.redirects, fh.config.Prefix, fh.exists, fh.serveRewrite) {
+		return
+	}
+	if r.Method == http.MethodPost && fh.config.Upload {
+		fh.serveUpload(w, r, path.Clean(upath))
+		return
+	}
+	fh.serveFile(w, r, path.Clean(upath), true)
+}
+
+// exists reports whether there is a file or directory for upath, which
+// shadows the redirect rules that are not forced.
+func (fh *fileHandler) exists(upath string) bool {
+	f, err := fh.root.Open(path.Clean(upath))
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
+
+// serveRewrite serves the file upath in place of the requested one.
+func (fh *fileHandler) serveRewrite(w http.ResponseWriter, r *http.Request, upath string) {
+	fh.serveFile(w, r, upath, false)
 }
 
 // name is '/'-separated, not filepath.Separator.
-func serveFile(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name string, redirect bool) {
+func (fh *fileHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, redirect bool) {
 	const indexPage = "/index.html"
 
 	// redirect .../index.html to .../
@@ -176,18 +267,26 @@ func serveFile(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name
 		return
 	}
 
-	f, err := fs.Open(name)
+	f, err := fh.root.Open(name)
 	if err != nil {
-		msg, code := toHTTPError(err)
-		http.Error(w, msg, code)
+		if errors.Is(err, fs.ErrNotExist) && path.Base(name) == sumsFile && fh.serveSums(w, r, path.Dir(name)) {
+			return
+		}
+		if fh.config.SPA {
+			addVary(w.Header(), "Accept")
+		}
+		if fh.wantsFallback(r, name, err) {
+			fh.serveFile(w, r, path.Clean("/"+fh.config.SPAFallback), false)
+			return
+		}
+		fh.serveError(w, r, err)
 		return
 	}
 	defer f.Close()
 
 	d, err := f.Stat()
 	if err != nil {
-		msg, code := toHTTPError(err)
-		http.Error(w, msg, code)
+		fh.serveError(w, r, err)
 		return
 	}
 
@@ -216,33 +315,103 @@ func serveFile(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name
 			return
 		}
 
+		// ?archive= downloads the whole directory, even if it has an index.html
+		if format := r.URL.Query().Get(archiveParam); format != "" {
+			if fh.config.NoListing {
+				fh.writeError(w, r, "Directory listing is disabled", http.StatusForbidden)
+				return
+			}
+			fh.serveArchive(w, r, name, d, format)
+			return
+		}
+
 		// use contents of index.html for directory, if present
 		index := strings.TrimSuffix(name, "/") + indexPage
-		ff, err := fs.Open(index)
+		ff, err := fh.root.Open(index)
 		if err == nil {
 			defer ff.Close()
 			dd, err := ff.Stat()
 			if err == nil {
 				d = dd
 				f = ff
+				name = index
 			}
 		}
 	}
 
 	// Still a directory? (we didn't find an index.html file)
 	if d.IsDir() {
+		if fh.config.NoListing {
+			fh.writeError(w, r, "Directory listing is disabled", http.StatusForbidden)
+			return
+		}
 		if checkIfModifiedSince(r, d.ModTime()) == condFalse {
 			writeNotModified(w)
 			return
 		}
 		setLastModified(w, d.ModTime())
-		dirList(w, r, f)
+		fh.dirList(w, r, name, f)
+		return
+	}
+
+	if algorithm := r.URL.Query().Get(checksumParam); algorithm != "" {
+		fh.serveChecksum(w, r, name, d, algorithm)
+		return
+	}
+
+	if r.URL.Query().Get(thumbParam) != "" {
+		fh.serveThumbnail(w, r, name, d, f)
+		return
+	}
+
+	if fh.serveMarkdown(w, r, d, f) {
+		return
+	}
+
+	if fh.serveSource(w, r, d, f) {
+		return
+	}
+
+	if fh.serveMedia(w, r, d) {
+		return
+	}
+
+	fh.setETag(w, name, d)
+
+	if fh.servePrecompressed(w, r, name, d) {
 		return
 	}
 
 	// serveContent will check modification time
 	sizeFunc := func() (int64, error) { return d.Size(), nil }
-	serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, f)
+	fh.serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, f)
+}
+
+// wantsFallback reports whether a request for the missing file name must be
+// answered with the single-page application fallback instead of a 404.
+// Paths with a file extension, the excluded ones and requests asking for
+// JSON keep failing, so missing assets and API calls are not masked by the
+// application shell.
+func (fh *fileHandler) wantsFallback(r *http.Request, name string, err error) bool {
+	if !fh.config.SPA || !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || wantsJSON(r) {
+		return false
+	}
+	if name == path.Clean("/"+fh.config.SPAFallback) || path.Ext(name) != "" {
+		return false
+	}
+	for _, exclude := range fh.config.SPAExclude {
+		exclude = path.Clean("/" + exclude)
+		if name == exclude || strings.HasPrefix(name, strings.TrimSuffix(exclude, "/")+"/") {
+			return false
+		}
+		if ok, _ := path.Match(exclude, name); ok {
+			return false
+		}
+	}
+	return true
 }
 
 // localRedirect gives a Moved Permanently response.
@@ -366,7 +535,7 @@ func wrapFprintf(w io.Writer, format string, a ...any) {
 	_, _ = fmt.Fprintf(w, format, a...)
 }
 
-func dirList(w http.ResponseWriter, r *http.Request, f http.File) {
+func (fh *fileHandler) dirList(w http.ResponseWriter, r *http.Request, name string, f http.File) {
 	// Prefer to use ReadDir instead of Readdir,
 	// because the former doesn't require calling
 	// Stat on every entry of a directory on Unix.
@@ -384,50 +553,49 @@ func dirList(w http.ResponseWriter, r *http.Request, f http.File) {
 
 	if err != nil {
 		logf(r, "http: error reading directory: %v", err)
-		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		fh.writeError(w, r, "Error reading directory", http.StatusInternalServerError)
 		return
 	}
-	sort.Slice(dirs, func(i, j int) bool { return dirs.name(i) < dirs.name(j) })
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	info, _ := f.Stat()
-	if info.Name() != "." {
-		wrapFprintf(w, "<a href=\"%s\">%s</a>\n", "..", "↑ Parent directory")
+
+	data := newListingData(r, dirs, name == "/" && fh.config.Prefix == "")
+	data.Path = fh.config.Prefix + data.Path
+	data.Base = fh.config.Prefix
+	data.Upload = fh.config.Upload && !fh.config.ReadOnly
+	data.Commit = fh.commit
+	data.addSums()
+	if data.Images = data.setMedia(); data.Images {
+		data.Gallery = r.URL.Query().Get(viewParam) == viewGallery
+		data.ListURL, data.GalleryURL = data.viewURL(""), data.viewURL(viewGallery)
 	}
-	wrapFprintf(w, "<table>\n")
-	if dirs.len() == 0 {
-		wrapFprintf(w, "<h4>Directory is empty</h4>")
-	} else {
-		_, _ = fmt.Fprint(w, "<tr><td/><td>Name</a><td>Size</td><td>Last modified</td></tr>\n")
-	}
-	for i, n := 0, dirs.len(); i < n; i++ {
-		name := dirs.name(i)
-		size := dirs.size(i)
-		lastMod := dirs.lastModified(i)
-		fType := dirs.fType(i)
-		if dirs.isDir(i) {
-			name += "/"
-		}
-		// name may contain '?' or '#', which must be escaped to remain
-		// part of the URL path, and not indicate the start of a query
-		// string or fragment.
-		_url := url.URL{Path: name}
-		wrapFprintf(w, "<tr><td>[%s]</td><td><a href=\"%s\">%s</a><td>%s</td><td>%s</td></tr>\n",
-			fType,
-			_url.String(),
-			htmlReplacer.Replace(name),
-			humanizeSize(size),
-			lastMod.Format(time.RFC1123Z),
-		)
-	}
-	wrapFprintf(w, "</table>\n")
-	wrapFprintf(w, fileServerCss)
+	addVary(w.Header(), "Accept")
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	if readme := fh.readme(name, dirs); readme != nil {
+		data.README = template.HTML(readme)
+		data.Styles = markdownCss + markdownAnchors
+	}
+	data.Styles = fileServerCss + data.Styles
+	if data.Gallery {
+		data.Styles += galleryCss
+		data.Scripts = galleryLightbox
+	}
+	var buf bytes.Buffer
+	if err = fh.listing.Execute(&buf, data); err != nil {
+		logf(r, "http: error rendering directory: %v", err)
+		fh.writeError(w, r, "Error rendering directory", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
 }
 
 // if name is empty, filename is unknown. (used for mime type, before sniffing)
 // if modtime.IsZero(), modtime is unknown.
 // content must be seeked to the beginning of the file.
 // The sizeFunc is called at most once. Its error, if any, is sent in the HTTP response.
-func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, sizeFunc func() (int64, error), content io.ReadSeeker) {
+func (fh *fileHandler) serveContent(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, sizeFunc func() (int64, error), content io.ReadSeeker) {
 	setLastModified(w, modtime)
 	done, rangeReq := checkPreconditions(w, r, modtime)
 	if done {
@@ -449,7 +617,7 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 			ctype = http.DetectContentType(buf[:n])
 			_, err := content.Seek(0, io.SeekStart) // rewind to output whole file
 			if err != nil {
-				http.Error(w, "seeker can't seek", http.StatusInternalServerError)
+				fh.writeError(w, r, "seeker can't seek", http.StatusInternalServerError)
 				return
 			}
 		}
@@ -458,14 +626,23 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 		ctype = ctypes[0]
 	}
 
+	if fh.live != nil && rangeReq == "" && strings.HasPrefix(ctype, "text/html") {
+		page, pageSize, err := fh.live.inject(content)
+		if err != nil {
+			fh.writeError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, sizeFunc = page, func() (int64, error) { return pageSize, nil }
+	}
+
 	size, err := sizeFunc()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fh.writeError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if size < 0 {
 		// Should never happen but just to be sure
-		http.Error(w, "negative content size computed", http.StatusInternalServerError)
+		fh.writeError(w, r, "negative content size computed", http.StatusInternalServerError)
 		return
 	}
 
@@ -487,7 +664,7 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
 		fallthrough
 	default:
-		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		fh.writeError(w, r, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 		return
 	}
 
@@ -513,7 +690,7 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 		// multipart responses."
 		ra := ranges[0]
 		if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
-			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			fh.writeError(w, r, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 			return
 		}
 		sendSize = ra.length
@@ -550,15 +727,45 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 	}
 
 	w.Header().Set("Accept-Ranges", "bytes")
-	if w.Header().Get("Content-Encoding") == "" {
+	encoding := fh.contentEncoding(w, r, ctype, size, rangeReq)
+	if encoding == "" {
 		w.Header().Set("Content-Length", strconv.FormatInt(sendSize, 10))
+	} else {
+		w.Header().Set("Content-Encoding", encoding)
+		if etag := w.Header().Get("Etag"); etag != "" {
+			w.Header().Set("Etag", weakETag(etag))
+		}
 	}
 
 	w.WriteHeader(code)
 
 	if r.Method != "HEAD" {
-		_, _ = io.CopyN(w, sendContent, sendSize)
+		if encoding == "" {
+			_, _ = io.CopyN(w, sendContent, sendSize)
+			return
+		}
+		enc, err := newEncoder(w, encoding)
+		if err != nil {
+			logf(r, "http: error creating %s encoder: %v", encoding, err)
+			return
+		}
+		_, _ = io.CopyN(enc, sendContent, sendSize)
+		_ = enc.Close()
+	}
+}
+
+// contentEncoding decides whether the response is compressed on the fly and
+// returns the chosen encoding, or "" to send it as is. Range requests are
+// always served uncompressed, so byte offsets keep referring to the file.
+func (fh *fileHandler) contentEncoding(w http.ResponseWriter, r *http.Request, ctype string, size int64, rangeReq string) string {
+	if !fh.config.Compress || !isCompressible(ctype) || w.Header().Get("Content-Encoding") != "" {
+		return ""
+	}
+	addVary(w.Header(), "Accept-Encoding")
+	if rangeReq != "" || size < fh.config.CompressMinSize {
+		return ""
 	}
+	return negotiateEncoding(r, supportedEncodings)
 }
 
 // logf prints to the ErrorLog of the *Server associated with request r
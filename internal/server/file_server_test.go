@@ -0,0 +1,66 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":    "<app>",
+		"200.html":      "<shell>",
+		"assets/app.js": "console.log(1)",
+	})
+	spa := Configuration{SPA: true, SPAFallback: "/index.html", SPAExclude: []string{"/api", "/legacy/*"}}
+
+	tt := []struct {
+		name     string
+		config   Configuration
+		method   string
+		target   string
+		accept   string
+		status   int
+		expected string
+	}{
+		{"navigation", spa, http.MethodGet, "/dashboard/settings", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusOK, "<app>"},
+		{"without accept", spa, http.MethodGet, "/dashboard", "", http.StatusOK, "<app>"},
+		{"head", spa, http.MethodHead, "/dashboard", "text/html", http.StatusOK, ""},
+		{"custom fallback", Configuration{SPA: true, SPAFallback: "200.html"}, http.MethodGet, "/dashboard", "text/html", http.StatusOK, "<shell>"},
+		{"existing asset", spa, http.MethodGet, "/assets/app.js", "*/*", http.StatusOK, "console.log(1)"},
+		{"index redirect is kept", spa, http.MethodGet, "/index.html", "text/html", http.StatusMovedPermanently, ""},
+		{"missing asset", spa, http.MethodGet, "/assets/missing.js", "*/*", http.StatusNotFound, ""},
+		{"excluded path", spa, http.MethodGet, "/api/users", "text/html", http.StatusNotFound, ""},
+		{"excluded pattern", spa, http.MethodGet, "/legacy/report", "text/html", http.StatusNotFound, ""},
+		{"json request", spa, http.MethodGet, "/users", "application/json", http.StatusNotFound, ""},
+		{"json format", spa, http.MethodGet, "/users?format=json", "", http.StatusNotFound, ""},
+		{"other methods", spa, http.MethodDelete, "/dashboard", "text/html", http.StatusNotFound, ""},
+		{"disabled", Configuration{}, http.MethodGet, "/dashboard", "text/html", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			config.Path = dir
+			handler := FileServer(http.Dir(dir), config)
+			r := httptest.NewRequest(tc.method, tc.target, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				assert.Equal(t, tc.expected, w.Body.String())
+			}
+			if config.SPA && tc.status != http.StatusOK && tc.status != http.StatusMovedPermanently {
+				assert.Contains(t, w.Header().Values("Vary"), "Accept")
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	viewGallery = "gallery"
+	thumbParam  = "thumb"
+
+	mediaImage = "image"
+	mediaAudio = "audio"
+	mediaVideo = "video"
+
+	// thumbSize is the maximum width and height of thumbnails.
+	thumbSize      = 256
+	thumbCacheSize = 512
+	// thumbMaxPixels protects the server from decoding huge images, which
+	// are sent as they are, and thumbDecodes bounds the images decoded at
+	// the same time, as each one takes 4 bytes per pixel.
+	thumbMaxPixels = 25_000_000
+	thumbDecodes   = 2
+)
+
+var errImageTooLarge = errors.New("image too large for a thumbnail")
+
+// thumbSlots limits the concurrent decodes of thumbnail.
+var thumbSlots = make(chan struct{}, thumbDecodes)
+
+const galleryCss = `
+	<style>
+		.gallery {
+			display: grid;
+			grid-template-columns: repeat(auto-fill, minmax(180px, 1fr));
+			gap: 16px;
+			max-width: 1200px;
+		}
+
+		.gallery a {
+			display: flex;
+			flex-direction: column;
+			align-items: center;
+			text-decoration: none;
+			font-size: 13px;
+			word-break: break-all;
+		}
+
+		.gallery .thumb {
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			width: 100%;
+			height: 160px;
+			margin-bottom: 6px;
+			background-color: whitesmoke;
+			border-radius: 6px;
+			overflow: hidden;
+		}
+
+		.gallery img {
+			max-width: 100%;
+			max-height: 100%;
+		}
+
+		#lightbox {
+			position: fixed;
+			inset: 0;
+			display: flex;
+			flex-direction: column;
+			align-items: center;
+			justify-content: center;
+			background-color: rgba(0, 0, 0, 0.85);
+			cursor: zoom-out;
+		}
+
+		#lightbox[hidden] {
+			display: none;
+		}
+
+		#lightbox img {
+			max-width: 92vw;
+			max-height: 88vh;
+		}
+
+		#lightbox p {
+			color: white;
+		}
+	</style>
+	`
+
+// galleryLightbox opens the images of the gallery over the page. Arrows
+// move between them, and escape or a click closes it.
+const galleryLightbox = `
+	<script>
+		(function () {
+			var lightbox = document.getElementById("lightbox");
+			var links = Array.prototype.slice.call(document.querySelectorAll(".gallery a[data-image]"));
+			var current = -1;
+			function show(i) {
+				current = (i + links.length) % links.length;
+				lightbox.querySelector("img").src = links[current].href;
+				lightbox.querySelector("p").textContent = links[current].dataset.name;
+				lightbox.hidden = false;
+			}
+			links.forEach(function (link, i) {
+				link.addEventListener("click", function (e) {
+					if (!e.ctrlKey && !e.metaKey) {
+						e.preventDefault();
+						show(i);
+					}
+				});
+			});
+			lightbox.addEventListener("click", function () {
+				lightbox.hidden = true;
+			});
+			document.addEventListener("keydown", function (e) {
+				if (lightbox.hidden) {
+					return;
+				}
+				if (e.key === "Escape") {
+					lightbox.hidden = true;
+				} else if (e.key === "ArrowRight") {
+					show(current + 1);
+				} else if (e.key === "ArrowLeft") {
+					show(current - 1);
+				}
+			});
+		})();
+	</script>
+	`
+
+const mediaCss = `
+	<style>
+		audio, video {
+			display: block;
+			margin-top: 20px;
+			max-width: 100%;
+			max-height: 85vh;
+		}
+	</style>
+	`
+
+// mediaKind returns whether name is an image, audio or video file.
+func mediaKind(name string) string {
+	ctype, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(name)))
+	kind, _, _ := strings.Cut(ctype, "/")
+	switch kind {
+	case mediaImage, mediaAudio, mediaVideo:
+		return kind
+	default:
+		return ""
+	}
+}
+
+// hasThumbnail reports whether a thumbnail can be generated for name.
+func hasThumbnail(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// setMedia fills the media of the entries, and reports whether there is any
+// image. Images are shown by their thumbnails, if there are any.
+func (d *listingData) setMedia() bool {
+	images := false
+	for i, e := range d.Entries {
+		if e.IsDir {
+			continue
+		}
+		d.Entries[i].Media = mediaKind(e.Name)
+		if d.Entries[i].Media == mediaImage {
+			images = true
+			d.Entries[i].Thumbnail = e.URL
+			if hasThumbnail(e.Name) {
+				d.Entries[i].Thumbnail += "?" + thumbParam + "=1"
+			}
+		}
+	}
+	return images
+}
+
+// viewURL returns the URL of the listing in the given view, keeping the
+// current order.
+func (d *listingData) viewURL(view string) string {
+	return listingURL(d.Sort, d.Order, view)
+}
+
+// serveThumbnail replies with a thumbnail of the JPEG, PNG or GIF image f.
+// Thumbnails are cached until the image changes.
+func (fh *fileHandler) serveThumbnail(w http.ResponseWriter, r *http.Request, name string, d fs.FileInfo, f io.ReadSeeker) {
+	if !hasThumbnail(name) {
+		fh.writeError(w, r, "Thumbnails are only available for JPEG, PNG and GIF images", http.StatusBadRequest)
+		return
+	}
+	thumb, ok := fh.thumbs.get(name, d)
+	if !ok {
+		var err error
+		if thumb, err = thumbnail(f); err != nil {
+			logf(r, "http: error generating thumbnail: %v", err)
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				fh.serveError(w, r, err)
+				return
+			}
+			// Let the browser scale the original
+			sizeFunc := func() (int64, error) { return d.Size(), nil }
+			fh.serveContent(w, r, d.Name(), d.ModTime(), sizeFunc, f)
+			return
+		}
+		fh.thumbs.put(name, d, thumb)
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(thumb))
+	content := bytes.NewReader(thumb)
+	sizeFunc := func() (int64, error) { return content.Size(), nil }
+	fh.serveContent(w, r, "", d.ModTime(), sizeFunc, content)
+}
+
+// thumbnail scales the image read from r to fit in thumbSize. JPEG images
+// give JPEG thumbnails, and the rest PNG ones to keep their transparency.
+func thumbnail(r io.ReadSeeker) ([]byte, error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > thumbMaxPixels {
+		return nil, errImageTooLarge
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	thumbSlots <- struct{}{}
+	defer func() { <-thumbSlots }()
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbSize || height > thumbSize {
+		if width > height {
+			width, height = thumbSize, height*thumbSize/width
+		} else {
+			width, height = width*thumbSize/height, thumbSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max1(width), max1(height)))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// serveMedia shows audio and video files in an HTML5 player for browsers,
+// and reports whether it did. The player gets the file with ?raw=1, so it
+// is served through serveContent with Range support.
+func (fh *fileHandler) serveMedia(w http.ResponseWriter, r *http.Request, d fs.FileInfo) bool {
+	kind := mediaKind(d.Name())
+	if kind != mediaAudio && kind != mediaVideo {
+		return false
+	}
+	addVary(w.Header(), "Accept")
+	if !wantsHTML(r) || r.URL.Query().Get(rawParam) != "" {
+		return false
+	}
+	if checkIfModifiedSince(r, d.ModTime()) == condFalse {
+		writeNotModified(w)
+		return true
+	}
+	src := url.URL{Path: d.Name(), RawQuery: rawParam + "=1"}
+	setLastModified(w, d.ModTime())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wrapFprintf(w, "<title>%s</title>\n", htmlReplacer.Replace(d.Name()))
+	wrapFprintf(w, "<a href=\"?%s=1\">View raw</a>\n", rawParam)
+	wrapFprintf(w, "<%s controls autoplay src=\"%s\"></%s>\n", kind, htmlReplacer.Replace(src.String()), kind)
+	_, _ = io.WriteString(w, fileServerCss)
+	_, _ = io.WriteString(w, mediaCss)
+	if fh.live != nil {
+		_, _ = io.WriteString(w, fh.live.script)
+	}
+	return true
+}
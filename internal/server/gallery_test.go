@@ -0,0 +1,127 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	tt := []struct {
+		name           string
+		width, height  int
+		format         string
+		expectedWidth  int
+		expectedHeight int
+		expectedFormat string
+	}{
+		{
+			"landscape",
+			1024,
+			512,
+			"png",
+			256,
+			128,
+			"png",
+		},
+		{
+			"portrait",
+			300,
+			600,
+			"jpeg",
+			128,
+			256,
+			"jpeg",
+		},
+		{
+			"small images keep their size",
+			100,
+			50,
+			"png",
+			100,
+			50,
+			"png",
+		},
+		{
+			"thin images keep a pixel",
+			2048,
+			4,
+			"png",
+			256,
+			1,
+			"png",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			src := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+			if tc.format == "jpeg" {
+				assert.NoError(t, jpeg.Encode(&buf, src, nil))
+			} else {
+				assert.NoError(t, png.Encode(&buf, src))
+			}
+			res, err := thumbnail(bytes.NewReader(buf.Bytes()))
+			assert.NoError(t, err)
+			config, format, err := image.DecodeConfig(bytes.NewReader(res))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFormat, format)
+			assert.Equal(t, tc.expectedWidth, config.Width)
+			assert.Equal(t, tc.expectedHeight, config.Height)
+		})
+	}
+}
+
+func TestGalleryListing(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 512, 512))))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "photo.png"), buf.Bytes(), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o644))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+
+	tt := []struct {
+		name     string
+		target   string
+		expected []string
+	}{
+		{"list", "/", []string{`href="?order=asc&amp;sort=name&amp;view=gallery">gallery`, `href="?order=desc&amp;sort=name">Name`}},
+		{"gallery", "/?view=gallery", []string{`class="gallery"`, `href="?order=desc&amp;sort=name&amp;view=gallery">Name`, `href="?order=asc&amp;sort=size&amp;view=gallery">Size`}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			r.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+			for _, expected := range tc.expected {
+				assert.Contains(t, w.Body.String(), expected)
+			}
+		})
+	}
+
+	thumb := func() []byte {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/photo.png?thumb=1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.Bytes()
+	}
+	first := thumb()
+	config, err := png.DecodeConfig(bytes.NewReader(first))
+	assert.NoError(t, err)
+	assert.Equal(t, thumbSize, config.Width)
+	assert.Equal(t, first, thumb())
+}
@@ -0,0 +1,126 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitCommit is the commit served by a gitFileSystem.
+type gitCommit struct {
+	Hash    string    `json:"hash"`
+	Ref     string    `json:"ref"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+func (c *gitCommit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// gitFileSystem serves the tree of a commit straight from the object store
+// of a repository, through the git command. Every file has the date of the
+// commit, as git doesn't keep modification times.
+type gitFileSystem struct {
+	http.FileSystem
+	commit *gitCommit
+}
+
+// openGitFS returns the tree of ref for the directory dir, which can be the
+// root of the work tree of a repository or any of its subdirectories.
+func openGitFS(dir, ref string) (gitFileSystem, error) {
+	hash, err := git(dir, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return gitFileSystem{}, err
+	}
+	commit := &gitCommit{Hash: strings.TrimSpace(string(hash)), Ref: ref}
+	out, err := git(dir, "show", "-s", "--format=%an%x00%aI%x00%s", commit.Hash)
+	if err != nil {
+		return gitFileSystem{}, err
+	}
+	if fields := strings.SplitN(strings.TrimSpace(string(out)), "\x00", 3); len(fields) == 3 {
+		commit.Author, commit.Subject = fields[0], fields[2]
+		commit.Date, _ = time.Parse(time.RFC3339, fields[1])
+	}
+
+	prefix, err := git(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return gitFileSystem{}, err
+	}
+	treeish := commit.Hash + ":" + strings.TrimSpace(string(prefix))
+	out, err = git(dir, "ls-tree", "-r", "-t", "-l", "-z", "--full-tree", treeish)
+	if err != nil {
+		return gitFileSystem{}, err
+	}
+
+	tree := newTreeFS(commit.Date)
+	for _, line := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			continue
+		}
+		switch objectType, object := fields[1], fields[2]; {
+		case objectType == "tree":
+			tree.addDir(name, commit.Date)
+		case objectType == "blob" && fields[0] != "120000":
+			size, _ := strconv.ParseInt(fields[3], 10, 64)
+			tree.addFile(name, commit.Date, size, func() (io.ReadSeeker, error) {
+				open := func() (io.ReadCloser, error) { return gitBlob(dir, object) }
+				return &streamReader{open: open, size: size}, nil
+			})
+		}
+		// Symbolic links and submodules are left out
+	}
+	return gitFileSystem{FileSystem: http.FS(tree), commit: commit}, nil
+}
+
+// gitBlob streams the content of the blob object from a git cat-file
+// process, which ends when the stream is closed. Files are only read when
+// their content is, so neither stat calls nor revalidations start one.
+func gitBlob(dir, object string) (io.ReadCloser, error) {
+	cmd := exec.Command("git", "-C", dir, "cat-file", "blob", object)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return readCloser{Reader: out, close: func() error {
+		// Stop git if the blob wasn't read until the end
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil
+	}}, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,125 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestOpenGitFS(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":           "<html>home</html>",
+		"with space.txt":       "space",
+		"ñandú.txt":            "ñandú",
+		"docs/guide/intro.md":  "# intro",
+		"docs/guide/empty.txt": "",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+	hasLink := os.Symlink("index.html", filepath.Join(dir, "link.html")) == nil
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=Servant", "-c", "user.email=servant@example.com", "commit", "-q", "-m", "Initial commit"},
+	} {
+		_, err := git(dir, args...)
+		assert.NoError(t, err)
+	}
+	// The work tree is not served
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("changed"), 0o644))
+
+	gfs, err := openGitFS(dir, "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "Servant", gfs.commit.Author)
+	assert.Equal(t, "Initial commit", gfs.commit.Subject)
+	assert.Len(t, gfs.commit.Hash, 40)
+
+	read := func(t *testing.T, gfs gitFileSystem, name string) string {
+		f, err := gfs.Open(name)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		return string(content)
+	}
+
+	tt := []struct {
+		name     string
+		file     string
+		expected string
+	}{
+		{"committed content", "/index.html", "<html>home</html>"},
+		{"space in the name", "/with space.txt", "space"},
+		{"quoted name without -z", "/ñandú.txt", "ñandú"},
+		{"nested", "/docs/guide/intro.md", "# intro"},
+		{"empty", "/docs/guide/empty.txt", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, read(t, gfs, tc.file))
+		})
+	}
+
+	root, err := gfs.Open("/")
+	assert.NoError(t, err)
+	infos, err := root.Readdir(-1)
+	assert.NoError(t, err)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"docs", "index.html", "with space.txt", "ñandú.txt"}, names)
+	if hasLink {
+		_, err = gfs.Open("/link.html")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+
+	// Blobs are streamed, and can be read from any offset
+	f, err := gfs.Open("/docs/guide/intro.md")
+	assert.NoError(t, err)
+	_, err = f.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "intro", string(content))
+	assert.NoError(t, f.Close())
+
+	// and concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "# intro", read(t, gfs, "/docs/guide/intro.md"))
+		}()
+	}
+	wg.Wait()
+
+	// A subdirectory serves its part of the tree
+	sub, err := openGitFS(filepath.Join(dir, "docs"), "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "# intro", read(t, sub, "/guide/intro.md"))
+
+	_, err = openGitFS(dir, "missing")
+	assert.Error(t, err)
+}
@@ -26,13 +26,15 @@ type localHandler struct {
 	config   Configuration
 	requests *Requests
 	output   Output
+	faults   *faultInjector
 }
 
-func newLocalHandler(config Configuration, output Output) RequestHandler {
+func newLocalHandler(config Configuration, output Output, faults *faultInjector) RequestHandler {
 	return &localHandler{
 		config:   config,
 		output:   output,
 		requests: NewRequestManager(),
+		faults:   faults,
 	}
 }
 
@@ -40,12 +42,36 @@ func (lh *localHandler) Handle(h http.Handler) http.Handler {
 	requestHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		lrw := network.NewLoggingResponseWriter(w)
+		fault := lh.faults.pick(r)
+		if fault != nil && fault.latency > 0 {
+			sleep(r.Context(), fault.latency)
+		}
 		if lh.config.CORS {
 			w.Header().Set(network.AccessControlAllowOrigin, "*")
 			w.Header().Set(network.AccessControlAllowMethods, "*")
 		}
-		h.ServeHTTP(lrw, r)
-		logRequest(start, w, r, lrw, lh.requests, lh.output)
+		var tw *truncateWriter
+		switch {
+		case fault != nil && fault.reset:
+			if !resetConnection(w) {
+				panic(http.ErrAbortHandler)
+			}
+			lrw.StatusCode = 0
+		case fault != nil && fault.status != 0:
+			http.Error(lrw, http.StatusText(fault.status), fault.status)
+		case fault != nil && fault.truncate:
+			tw = newTruncateWriter(lrw)
+			h.ServeHTTP(newHeaderWriter(tw, r, lh.config.Headers), r)
+		default:
+			h.ServeHTTP(newHeaderWriter(lrw, r, lh.config.Headers), r)
+		}
+		logRequest(start, w, r, lrw, lh.requests, lh.output, fault)
+		if tw != nil && tw.truncated {
+			// Send what was written, and close the connection before the
+			// end of the body
+			_ = http.NewResponseController(w).Flush()
+			panic(http.ErrAbortHandler)
+		}
 	})
 
 	if lh.config.Auth != "" {
@@ -85,51 +111,65 @@ type proxyHandler struct {
 	client *http.Client
 }
 
-func newProxyHandler(config Configuration, output Output) RequestHandler {
+func newProxyHandler(config Configuration, output Output, faults *faultInjector) RequestHandler {
 	return &proxyHandler{
 		localHandler: localHandler{
 			config:   config,
 			output:   output,
 			requests: NewRequestManager(),
+			faults:   faults,
 		},
 		client: http.DefaultClient,
 	}
 }
 
 func (ph *proxyHandler) Handle(_ http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		lrw := network.NewLoggingResponseWriter(w)
+	return ph.localHandler.Handle(http.HandlerFunc(ph.proxy))
+}
 
-		proxyUrl := fmt.Sprintf("http://localhost:%d%s", ph.config.Port, r.URL.Path)
-		proxyReq, err := http.NewRequest(r.Method, proxyUrl, r.Body)
-		if err != nil {
-			log.Error("Error creating proxy request", err.Error())
-			return
-		}
-		proxyRes, err := ph.client.Do(proxyReq)
-		if err != nil {
-			log.Error("Error proxying request request", err.Error())
-			if errors.Is(err, syscall.ECONNREFUSED) {
-				errorMsg := fmt.Sprintf("SERVANT: Connection to local port %d was refused, check that your server is up and running", ph.config.Port)
-				_, _ = w.Write([]byte(errorMsg))
-			}
-			return
-		}
-		for k, v := range proxyRes.Header {
-			for _, hv := range v {
-				w.Header().Add(k, hv)
-			}
+func (ph *proxyHandler) proxy(w http.ResponseWriter, r *http.Request) {
+	if serveRedirects(w, r, ph.config.Redirects, "", nil, ph.rewrite) {
+		return
+	}
+	ph.forward(w, r)
+}
+
+func (ph *proxyHandler) rewrite(w http.ResponseWriter, r *http.Request, upath string) {
+	r.URL.Path = upath
+	ph.forward(w, r)
+}
+
+func (ph *proxyHandler) forward(w http.ResponseWriter, r *http.Request) {
+	proxyUrl := fmt.Sprintf("http://localhost:%d%s", ph.config.Port, r.URL.Path)
+	if r.URL.RawQuery != "" {
+		proxyUrl += "?" + r.URL.RawQuery
+	}
+	proxyReq, err := http.NewRequest(r.Method, proxyUrl, r.Body)
+	if err != nil {
+		log.Error("Error creating proxy request", err.Error())
+		return
+	}
+	proxyRes, err := ph.client.Do(proxyReq)
+	if err != nil {
+		log.Error("Error proxying request request", err.Error())
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			errorMsg := fmt.Sprintf("SERVANT: Connection to local port %d was refused, check that your server is up and running", ph.config.Port)
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(errorMsg))
 		}
-		w.WriteHeader(proxyRes.StatusCode)
-		_, err = io.Copy(w, proxyRes.Body)
-		if err != nil {
-			log.Error("Error copying body", err.Error())
-			return
+		return
+	}
+	defer proxyRes.Body.Close()
+	for k, v := range proxyRes.Header {
+		for _, hv := range v {
+			w.Header().Add(k, hv)
 		}
-
-		logRequest(start, w, r, lrw, ph.requests, ph.output)
-	})
+	}
+	w.WriteHeader(proxyRes.StatusCode)
+	_, err = io.Copy(w, proxyRes.Body)
+	if err != nil {
+		log.Error("Error copying body", err.Error())
+	}
 }
 
 func logRequest(
@@ -139,6 +179,7 @@ func logRequest(
 	lrw *network.LoggingResponseWriter,
 	requests *Requests,
 	output Output,
+	fault *fault,
 ) {
 	duration := time.Since(start)
 	contentType := w.Header().Get(network.ContentType)
@@ -156,6 +197,9 @@ func logRequest(
 		ContentType:   contentType,
 		ContentLength: unsignedContentLength,
 	}
+	if fault != nil {
+		request.Fault = fault.String()
+	}
 	// TODO: channels
 	requests.Add(request)
 	output.Write(request)
@@ -0,0 +1,161 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"fmt"
+	"github.com/planta7/servant/internal/network"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// mediaTypes are the top-level types that tell MIME type selectors of header
+// rules, like text/html or image/*, from path patterns like assets/*.
+var mediaTypes = map[string]bool{
+	"*": true, "application": true, "audio": true, "font": true, "image": true,
+	"message": true, "model": true, "multipart": true, "text": true, "video": true,
+}
+
+// HeaderRule sets the response header Name to Value, or removes it if Value
+// is empty. Without a Pattern the rule applies to every response, otherwise
+// to the paths matching a gitignore-style pattern or to the responses of a
+// MIME type (text/html, image/*).
+type HeaderRule struct {
+	Pattern string
+	Name    string
+	Value   string
+	paths   *ignoreRules
+}
+
+// ParseHeaderRules parses specs in the form "[pattern ]Name: value".
+func ParseHeaderRules(specs []string) ([]HeaderRule, error) {
+	var rules []HeaderRule
+	for _, spec := range specs {
+		rule, err := parseHeaderRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseHeaderRule(spec string) (HeaderRule, error) {
+	field, value, ok := strings.Cut(spec, ":")
+	if !ok {
+		return HeaderRule{}, fmt.Errorf("invalid header %q, expected \"[pattern ]Name: value\"", spec)
+	}
+	var rule HeaderRule
+	switch fields := strings.Fields(field); len(fields) {
+	case 1:
+		rule.Name = fields[0]
+	case 2:
+		rule.Pattern, rule.Name = fields[0], fields[1]
+	default:
+		return HeaderRule{}, fmt.Errorf("invalid header %q, expected \"[pattern ]Name: value\"", spec)
+	}
+	if strings.IndexFunc(rule.Name, isNotTokenChar) >= 0 {
+		return HeaderRule{}, fmt.Errorf("invalid header name %q", rule.Name)
+	}
+	rule.Name = http.CanonicalHeaderKey(rule.Name)
+	rule.Value = strings.TrimSpace(value)
+
+	if rule.Pattern != "" && !isMediaRange(rule.Pattern) {
+		if strings.HasPrefix(rule.Pattern, "!") || strings.HasPrefix(rule.Pattern, "#") {
+			return HeaderRule{}, fmt.Errorf("invalid header pattern %q", rule.Pattern)
+		}
+		rule.paths = newIgnoreRules([]string{rule.Pattern})
+		if len(rule.paths.patterns) == 0 {
+			return HeaderRule{}, fmt.Errorf("invalid header pattern %q", rule.Pattern)
+		}
+	}
+	return rule, nil
+}
+
+// isNotTokenChar reports whether r can't be part of a header name.
+// See https://www.rfc-editor.org/rfc/rfc9110#name-tokens.
+func isNotTokenChar(r rune) bool {
+	return r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+}
+
+func isMediaRange(pattern string) bool {
+	mediaType, subtype, ok := strings.Cut(pattern, "/")
+	return ok && mediaTypes[mediaType] && subtype != "" && !strings.Contains(subtype, "/")
+}
+
+// matches reports whether the rule applies to the response to upath with
+// the given content type.
+func (hr *HeaderRule) matches(upath, ctype string) bool {
+	switch {
+	case hr.Pattern == "":
+		return true
+	case hr.paths != nil:
+		return hr.paths.match(upath, strings.HasSuffix(upath, "/"))
+	}
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	if hr.Pattern == "*/*" || hr.Pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(hr.Pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// applyHeaderRules applies the matching rules in order, so later rules win
+// over former ones setting the same header.
+func applyHeaderRules(h http.Header, upath string, rules []HeaderRule) {
+	ctype := h.Get(network.ContentType)
+	for i := range rules {
+		if !rules[i].matches(upath, ctype) {
+			continue
+		}
+		if rules[i].Value == "" {
+			h.Del(rules[i].Name)
+		} else {
+			h.Set(rules[i].Name, rules[i].Value)
+		}
+	}
+}
+
+// headerWriter applies the header rules right before the response headers
+// are written, once the handler has set the content type.
+type headerWriter struct {
+	http.ResponseWriter
+	path        string
+	rules       []HeaderRule
+	wroteHeader bool
+}
+
+func newHeaderWriter(w http.ResponseWriter, r *http.Request, rules []HeaderRule) http.ResponseWriter {
+	if len(rules) == 0 {
+		return w
+	}
+	return &headerWriter{ResponseWriter: w, path: r.URL.Path, rules: rules}
+}
+
+func (hw *headerWriter) WriteHeader(code int) {
+	if !hw.wroteHeader {
+		hw.wroteHeader = true
+		applyHeaderRules(hw.Header(), hw.path, hw.rules)
+	}
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	if !hw.wroteHeader {
+		// net/http would sniff it after the rules are applied
+		if _, ok := hw.Header()[network.ContentType]; !ok {
+			hw.Header().Set(network.ContentType, http.DetectContentType(b))
+		}
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+func (hw *headerWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
@@ -0,0 +1,122 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHeaderRules(t *testing.T) {
+	tt := []struct {
+		name    string
+		spec    string
+		pattern string
+		header  string
+		value   string
+		wantErr bool
+	}{
+		{"every response", "x-foo: bar", "", "X-Foo", "bar", false},
+		{"path pattern", "/assets/** Cache-Control: public, max-age=31536000, immutable", "/assets/**", "Cache-Control", "public, max-age=31536000, immutable", false},
+		{"mime type", "text/html Cache-Control: no-store", "text/html", "Cache-Control", "no-store", false},
+		{"removal", "Server:", "", "Server", "", false},
+		{"value with colons", "Link: <https://example.com>; rel=preconnect", "", "Link", "<https://example.com>; rel=preconnect", false},
+		{"missing colon", "X-Foo bar", "", "", "", true},
+		{"too many fields", "a b X-Foo: bar", "", "", "", true},
+		{"invalid name", "/ X(Foo): bar", "", "", "", true},
+		{"negated pattern", "!*.html X-Foo: bar", "", "", "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := parseHeaderRule(tc.spec)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.pattern, rule.Pattern)
+			assert.Equal(t, tc.header, rule.Name)
+			assert.Equal(t, tc.value, rule.Value)
+		})
+	}
+}
+
+func TestHeaderWriter(t *testing.T) {
+	rules, err := ParseHeaderRules([]string{
+		"Cache-Control: no-cache",
+		"/assets/*.js Cache-Control: immutable",
+		"text/html Cache-Control: no-store",
+		"image/* X-Image: yes",
+		"docs/ X-Docs: yes",
+		"X-Powered-By:",
+	})
+	assert.NoError(t, err)
+
+	tt := []struct {
+		name     string
+		path     string
+		ctype    string
+		body     string
+		expected http.Header
+	}{
+		{
+			"default",
+			"/data.json",
+			"application/json",
+			"{}",
+			http.Header{"Cache-Control": {"no-cache"}, "Content-Type": {"application/json"}},
+		},
+		{
+			"path pattern",
+			"/assets/app.js",
+			"text/javascript",
+			"",
+			http.Header{"Cache-Control": {"immutable"}, "Content-Type": {"text/javascript"}},
+		},
+		{
+			"mime type with parameters",
+			"/index.html",
+			"text/html; charset=utf-8",
+			"",
+			http.Header{"Cache-Control": {"no-store"}, "Content-Type": {"text/html; charset=utf-8"}},
+		},
+		{
+			"mime type range",
+			"/logo.png",
+			"image/png",
+			"",
+			http.Header{"Cache-Control": {"no-cache"}, "Content-Type": {"image/png"}, "X-Image": {"yes"}},
+		},
+		{
+			"sniffed content type",
+			"/page",
+			"",
+			"<!DOCTYPE html><html></html>",
+			http.Header{"Cache-Control": {"no-store"}, "Content-Type": {"text/html; charset=utf-8"}},
+		},
+		{
+			"directory pattern",
+			"/docs/guide/",
+			"text/html; charset=utf-8",
+			"",
+			http.Header{"Cache-Control": {"no-store"}, "Content-Type": {"text/html; charset=utf-8"}, "X-Docs": {"yes"}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := newHeaderWriter(rec, httptest.NewRequest(http.MethodGet, tc.path, nil), rules)
+			w.Header().Set("X-Powered-By", "servant")
+			if tc.ctype != "" {
+				w.Header().Set("Content-Type", tc.ctype)
+			}
+			_, _ = w.Write([]byte(tc.body))
+			assert.Equal(t, tc.expected, rec.Header())
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bufio"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile holds the rules of a served tree, in its root.
+const ignoreFile = ".servantignore"
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreRules decides which files are hidden from the served tree, using
+// the gitignore syntax: patterns are checked in order and the last one
+// matching a path wins, so negated patterns (!) can re-include files.
+// A file inside an ignored directory is ignored too.
+type ignoreRules struct {
+	patterns []ignorePattern
+}
+
+// loadIgnoreRules builds the rules of the tree served from root: hidden
+// files (unless shown), the ignore file found in the root and the patterns
+// of the configuration, in this order.
+func loadIgnoreRules(root http.FileSystem, config Configuration) *ignoreRules {
+	var lines []string
+	if !config.ShowHidden {
+		lines = append(lines, ".*")
+	}
+	if f, err := root.Open("/" + ignoreFile); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		_ = f.Close()
+	}
+	lines = append(lines, config.Ignore...)
+	// the rules themselves are never served
+	lines = append(lines, "/"+ignoreFile, "/"+redirectsFile)
+	return newIgnoreRules(lines)
+}
+
+func newIgnoreRules(lines []string) *ignoreRules {
+	rules := &ignoreRules{}
+	for _, line := range lines {
+		if p, ok := compileIgnorePattern(line); ok {
+			rules.patterns = append(rules.patterns, p)
+		}
+	}
+	return rules
+}
+
+// compileIgnorePattern translates a gitignore line into a regular expression
+// that matches '/'-separated paths relative to the root.
+// See https://git-scm.com/docs/gitignore#_pattern_format.
+func compileIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// a separator at the beginning or in the middle anchors the pattern
+	// to the root, otherwise it matches at any level
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "/**") && i+3 == len(line):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = compiled
+	return p, true
+}
+
+// match reports whether name, '/'-separated and relative to the root, is
+// ignored. isDir tells whether name is a directory.
+func (ir *ignoreRules) match(name string, isDir bool) bool {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if ir.matchPath(strings.Join(parts[:i+1], "/"), isDir || !last) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ir *ignoreRules) matchPath(name string, isDir bool) bool {
+	ignored := false
+	for _, p := range ir.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(name) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// ignoreFileSystem hides the ignored files of the wrapped file system,
+// as if they didn't exist.
+type ignoreFileSystem struct {
+	http.FileSystem
+	rules *ignoreRules
+}
+
+func (ifs ignoreFileSystem) Open(name string) (http.File, error) {
+	f, err := ifs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if ifs.rules.match(name, info.IsDir()) {
+		_ = f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return ignoreHTTPFile{File: f, name: name, rules: ifs.rules}, nil
+}
+
+type ignoreHTTPFile struct {
+	http.File
+	name  string
+	rules *ignoreRules
+}
+
+func (f ignoreHTTPFile) Readdir(count int) ([]fs.FileInfo, error) {
+	list, err := f.File.Readdir(count)
+	visible := list[:0]
+	for _, info := range list {
+		if !f.rules.match(f.name+"/"+info.Name(), info.IsDir()) {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
+
+func (f ignoreHTTPFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	var list []fs.DirEntry
+	var err error
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		list, err = d.ReadDir(count)
+	} else {
+		var infos []fs.FileInfo
+		infos, err = f.File.Readdir(count)
+		for _, info := range infos {
+			list = append(list, fs.FileInfoToDirEntry(info))
+		}
+	}
+	visible := list[:0]
+	for _, entry := range list {
+		if !f.rules.match(f.name+"/"+entry.Name(), entry.IsDir()) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, err
+}
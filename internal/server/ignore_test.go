@@ -0,0 +1,161 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	tt := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{
+			"no patterns",
+			nil,
+			"/index.html",
+			false,
+			false,
+		},
+		{
+			"root is never ignored",
+			[]string{"*"},
+			"/",
+			true,
+			false,
+		},
+		{
+			"hidden file",
+			[]string{".*"},
+			"/.env",
+			false,
+			true,
+		},
+		{
+			"inside hidden directory",
+			[]string{".*"},
+			"/.git/config",
+			false,
+			true,
+		},
+		{
+			"base name at any level",
+			[]string{"node_modules"},
+			"/web/node_modules/react/index.js",
+			false,
+			true,
+		},
+		{
+			"anchored pattern",
+			[]string{"/build"},
+			"/web/build",
+			true,
+			false,
+		},
+		{
+			"anchored pattern at root",
+			[]string{"/build"},
+			"/build/app.js",
+			false,
+			true,
+		},
+		{
+			"directory only pattern on file",
+			[]string{"logs/"},
+			"/logs",
+			false,
+			false,
+		},
+		{
+			"directory only pattern on directory",
+			[]string{"logs/"},
+			"/logs/today.log",
+			false,
+			true,
+		},
+		{
+			"wildcard does not cross directories",
+			[]string{"docs/*.md"},
+			"/docs/api/index.md",
+			false,
+			false,
+		},
+		{
+			"double star",
+			[]string{"docs/**/*.md"},
+			"/docs/api/v1/index.md",
+			false,
+			true,
+		},
+		{
+			"leading double star",
+			[]string{"**/secret"},
+			"/a/b/secret",
+			true,
+			true,
+		},
+		{
+			"trailing double star",
+			[]string{"tmp/**"},
+			"/tmp/a/b",
+			false,
+			true,
+		},
+		{
+			"negation",
+			[]string{"*.log", "!important.log"},
+			"/important.log",
+			false,
+			false,
+		},
+		{
+			"negated hidden file",
+			[]string{".*", "!.well-known"},
+			"/.well-known/security.txt",
+			false,
+			false,
+		},
+		{
+			"parent directory can't be re-included",
+			[]string{"private/", "!private/public.txt"},
+			"/private/public.txt",
+			false,
+			true,
+		},
+		{
+			"character class",
+			[]string{"*.[oa]"},
+			"/lib/x.a",
+			false,
+			true,
+		},
+		{
+			"comments and blank lines",
+			[]string{"# *.txt", "", "   "},
+			"/notes.txt",
+			false,
+			false,
+		},
+		{
+			"escaped hash",
+			[]string{`\#notes`},
+			"/#notes",
+			false,
+			true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := newIgnoreRules(tc.patterns)
+			res := rules.match(tc.path, tc.isDir)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"encoding/json"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sortParam   = "sort"
+	orderParam  = "order"
+	formatParam = "format"
+
+	sortName     = "name"
+	sortSize     = "size"
+	sortModified = "modified"
+	orderAsc     = "asc"
+	orderDesc    = "desc"
+	formatJSON   = "json"
+)
+
+const dirListTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Path}}</title>
+{{.Styles}}
+</head>
+<body>
+{{- if not .Root}}
+<a href="..">↑ Parent directory</a>
+{{- end}}
+{{- with .Commit}}
+<p class="commit"><code title="{{.Hash}}">{{.ShortHash}}</code> ({{.Ref}}) {{.Subject}} · {{.Author}} · {{date .Date}}</p>
+{{- end}}
+{{- if not .Virtual}}
+<form class="archives" action="{{.Base}}/_servant/search" method="get">
+	Download as <a href="?archive=zip">zip</a> · <a href="?archive=tar.gz">tar.gz</a>
+	{{- if .Entries}}
+	<input id="filter" type="search" placeholder="Filter" autocomplete="off">
+	{{- end}}
+	<input id="search" type="search" name="q" placeholder="Search" autocomplete="off">
+	<input type="hidden" name="path" value="{{.Path}}">
+</form>
+{{- end}}
+{{- if .Upload}}
+<form class="archives" method="post" enctype="multipart/form-data">
+	<input type="file" name="file" multiple required>
+	<button>Upload</button>
+</form>
+{{- end}}
+{{- if .Images}}
+<p class="archives">View as {{if .Gallery}}<a href="{{.ListURL}}">list</a> · gallery{{else}}list · <a href="{{.GalleryURL}}">gallery</a>{{end}}</p>
+{{- end}}
+{{- if .Entries}}
+{{- if .Gallery}}
+<p class="archives">Sort by {{range $i, $column := .Columns}}{{if $i}} · {{end}}<a href="{{$column.URL}}">{{$column.Label}}</a>{{$column.Arrow}}{{end}}</p>
+<div class="gallery">
+{{- range .Entries}}
+<a href="{{.URL}}" data-name="{{.Name}}"{{if .Thumbnail}} data-image{{end}}><span class="thumb">{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="" loading="lazy">{{else}}[{{.Type}}]{{end}}</span>{{.Name}}</a>
+{{- end}}
+</div>
+<div id="lightbox" hidden><img alt=""><p></p></div>
+{{- else}}
+<table>
+<tr><td></td>{{range .Columns}}<td><a href="{{.URL}}">{{.Label}}</a>{{.Arrow}}</td>{{end}}</tr>
+{{- range .Entries}}
+<tr data-name="{{.Name}}"><td>[{{.Type}}]</td><td><a href="{{.URL}}">{{.Name}}</a></td><td>{{size .Size}}</td><td>{{date .Modified}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if not .Virtual}}
+<script>
+	document.getElementById("filter").addEventListener("input", function (e) {
+		var value = e.target.value.toLowerCase();
+		document.querySelectorAll("[data-name]").forEach(function (entry) {
+			var name = entry.dataset.name.toLowerCase();
+			entry.style.display = name.indexOf(value) >= 0 ? "" : "none";
+		});
+	});
+</script>
+{{- end}}
+{{.Scripts}}
+{{- else}}
+<h4>Directory is empty</h4>
+{{- end}}
+{{- with .README}}
+<article class="markdown readme">
+{{.}}</article>
+{{- end}}
+</body>
+</html>
+`
+
+var listingFuncs = template.FuncMap{
+	"size": humanizeSize,
+	"date": func(t time.Time) string { return t.Format(time.RFC1123Z) },
+}
+
+var defaultDirList = template.Must(template.New("dirList").Funcs(listingFuncs).Parse(dirListTemplate))
+
+// listingEntry is a file or directory of a listing.
+type listingEntry struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	IsDir     bool      `json:"isDir"`
+	Type      string    `json:"type"`
+	Size      int64     `json:"size"`
+	Modified  time.Time `json:"modified"`
+	Media     string    `json:"media,omitempty"`
+	Thumbnail string    `json:"thumbnail,omitempty"`
+	regular   bool
+}
+
+// listingColumn is a sortable column header of a listing.
+type listingColumn struct {
+	Label string
+	URL   string
+	Arrow string
+}
+
+// listingData is the model of a directory listing, rendered as HTML or JSON.
+// Base is the URL prefix of the mount of the directory, and Virtual listings,
+// like the one of the mounts, offer neither archives nor search.
+type listingData struct {
+	Path       string          `json:"path"`
+	Root       bool            `json:"-"`
+	Sort       string          `json:"sort"`
+	Order      string          `json:"order"`
+	Entries    []listingEntry  `json:"entries"`
+	Columns    []listingColumn `json:"-"`
+	README     template.HTML   `json:"-"`
+	Styles     template.HTML   `json:"-"`
+	Base       string          `json:"-"`
+	Virtual    bool            `json:"-"`
+	Upload     bool            `json:"-"`
+	Commit     *gitCommit      `json:"commit,omitempty"`
+	Images     bool            `json:"-"`
+	Gallery    bool            `json:"-"`
+	ListURL    string          `json:"-"`
+	GalleryURL string          `json:"-"`
+	Scripts    template.HTML   `json:"-"`
+}
+
+// newListingData builds the listing of dirs, sorted as requested by r.
+func newListingData(r *http.Request, dirs anyDirs, root bool) *listingData {
+	query := r.URL.Query()
+	data := &listingData{
+		Path:    r.URL.Path,
+		Root:    root,
+		Sort:    query.Get(sortParam),
+		Order:   query.Get(orderParam),
+		Entries: []listingEntry{},
+	}
+	if data.Sort != sortSize && data.Sort != sortModified {
+		data.Sort = sortName
+	}
+	if data.Order != orderDesc {
+		data.Order = orderAsc
+	}
+
+	for i, n := 0, dirs.len(); i < n; i++ {
+		name := dirs.name(i)
+		if dirs.isDir(i) {
+			name += "/"
+		}
+		// name may contain '?' or '#', which must be escaped to remain
+		// part of the URL path, and not indicate the start of a query
+		// string or fragment.
+		_url := url.URL{Path: name}
+		data.Entries = append(data.Entries, listingEntry{
+			Name:     name,
+			URL:      _url.String(),
+			IsDir:    dirs.isDir(i),
+			Type:     dirs.fType(i),
+			Size:     dirs.size(i),
+			Modified: dirs.lastModified(i),
+			regular:  dirs.isRegular(i),
+		})
+	}
+	data.sortEntries()
+
+	// Sorting keeps the gallery, which is only shown if there are images
+	view := ""
+	if query.Get(viewParam) == viewGallery {
+		view = viewGallery
+	}
+	for _, column := range []struct{ sort, label string }{
+		{sortName, "Name"},
+		{sortSize, "Size"},
+		{sortModified, "Last modified"},
+	} {
+		order, arrow := orderAsc, ""
+		if column.sort == data.Sort {
+			if data.Order == orderAsc {
+				order, arrow = orderDesc, " ▲"
+			} else {
+				arrow = " ▼"
+			}
+		}
+		data.Columns = append(data.Columns, listingColumn{
+			Label: column.label,
+			URL:   listingURL(column.sort, order, view),
+			Arrow: arrow,
+		})
+	}
+	return data
+}
+
+// listingURL returns the relative URL of the listing with the given order
+// and view.
+func listingURL(sort, order, view string) string {
+	query := url.Values{sortParam: {sort}, orderParam: {order}}
+	if view != "" {
+		query.Set(viewParam, view)
+	}
+	return "?" + query.Encode()
+}
+
+func (d *listingData) sortEntries() {
+	less := func(a, b listingEntry) bool { return a.Name < b.Name }
+	switch d.Sort {
+	case sortSize:
+		less = func(a, b listingEntry) bool {
+			if a.Size == b.Size {
+				return a.Name < b.Name
+			}
+			return a.Size < b.Size
+		}
+	case sortModified:
+		less = func(a, b listingEntry) bool {
+			if a.Modified.Equal(b.Modified) {
+				return a.Name < b.Name
+			}
+			return a.Modified.Before(b.Modified)
+		}
+	}
+	sort.SliceStable(d.Entries, func(i, j int) bool {
+		if d.Order == orderDesc {
+			return less(d.Entries[j], d.Entries[i])
+		}
+		return less(d.Entries[i], d.Entries[j])
+	})
+}
+
+// wantsJSON reports whether the client asked for a JSON response, either
+// with ?format=json or through the Accept header.
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get(formatParam) == formatJSON {
+		return true
+	}
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(v)
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
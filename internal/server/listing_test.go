@@ -0,0 +1,210 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNewListingData(t *testing.T) {
+	now := time.Now()
+	files := fstest.MapFS{
+		"b.txt":     {Data: make([]byte, 30), ModTime: now},
+		"a.txt":     {Data: make([]byte, 10), ModTime: now.Add(2 * time.Hour)},
+		"c":         {Mode: fs.ModeDir, ModTime: now.Add(time.Hour)},
+		"what?.txt": {Data: make([]byte, 20), ModTime: now.Add(3 * time.Hour)},
+	}
+	entries, err := fs.ReadDir(files, ".")
+	assert.NoError(t, err)
+	var dirs fileInfoDirs
+	for _, e := range entries {
+		info, err := e.Info()
+		assert.NoError(t, err)
+		dirs = append(dirs, info)
+	}
+
+	tt := []struct {
+		name    string
+		query   string
+		sort    string
+		order   string
+		entries []string
+		columns []listingColumn
+	}{
+		{
+			"default",
+			"",
+			sortName,
+			orderAsc,
+			[]string{"a.txt", "b.txt", "c/", "what?.txt"},
+			[]listingColumn{
+				{"Name", "?order=desc&sort=name", " ▲"},
+				{"Size", "?order=asc&sort=size", ""},
+				{"Last modified", "?order=asc&sort=modified", ""},
+			},
+		},
+		{
+			"name descending",
+			"?sort=name&order=desc",
+			sortName,
+			orderDesc,
+			[]string{"what?.txt", "c/", "b.txt", "a.txt"},
+			[]listingColumn{
+				{"Name", "?order=asc&sort=name", " ▼"},
+				{"Size", "?order=asc&sort=size", ""},
+				{"Last modified", "?order=asc&sort=modified", ""},
+			},
+		},
+		{
+			"size",
+			"?sort=size",
+			sortSize,
+			orderAsc,
+			[]string{"c/", "a.txt", "what?.txt", "b.txt"},
+			[]listingColumn{
+				{"Name", "?order=asc&sort=name", ""},
+				{"Size", "?order=desc&sort=size", " ▲"},
+				{"Last modified", "?order=asc&sort=modified", ""},
+			},
+		},
+		{
+			"modified descending",
+			"?sort=modified&order=desc",
+			sortModified,
+			orderDesc,
+			[]string{"what?.txt", "a.txt", "c/", "b.txt"},
+			[]listingColumn{
+				{"Name", "?order=asc&sort=name", ""},
+				{"Size", "?order=asc&sort=size", ""},
+				{"Last modified", "?order=asc&sort=modified", " ▼"},
+			},
+		},
+		{
+			"unknown values",
+			"?sort=owner&order=random",
+			sortName,
+			orderAsc,
+			[]string{"a.txt", "b.txt", "c/", "what?.txt"},
+			[]listingColumn{
+				{"Name", "?order=desc&sort=name", " ▲"},
+				{"Size", "?order=asc&sort=size", ""},
+				{"Last modified", "?order=asc&sort=modified", ""},
+			},
+		},
+		{
+			"gallery",
+			"?sort=size&view=gallery",
+			sortSize,
+			orderAsc,
+			[]string{"c/", "a.txt", "what?.txt", "b.txt"},
+			[]listingColumn{
+				{"Name", "?order=asc&sort=name&view=gallery", ""},
+				{"Size", "?order=desc&sort=size&view=gallery", " ▲"},
+				{"Last modified", "?order=asc&sort=modified&view=gallery", ""},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			data := newListingData(httptest.NewRequest(http.MethodGet, "/"+tc.query, nil), dirs, true)
+			assert.Equal(t, tc.sort, data.Sort)
+			assert.Equal(t, tc.order, data.Order)
+			var names []string
+			for _, e := range data.Entries {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, tc.entries, names)
+			assert.Equal(t, tc.columns, data.Columns)
+		})
+	}
+}
+
+func TestListingURLs(t *testing.T) {
+	dirs := fileInfoDirs{fileInfo(t, 1, time.Now())}
+	data := newListingData(httptest.NewRequest(http.MethodGet, "/", nil), dirs, true)
+	assert.Equal(t, "file", data.Entries[0].URL)
+
+	files := fstest.MapFS{"what?#.txt": {}, "dir": {Mode: fs.ModeDir}}
+	entries, err := fs.ReadDir(files, ".")
+	assert.NoError(t, err)
+	data = newListingData(httptest.NewRequest(http.MethodGet, "/", nil), dirEntryDirs(entries), true)
+	assert.Equal(t, "dir/", data.Entries[0].URL)
+	assert.True(t, data.Entries[0].IsDir)
+	assert.Equal(t, "D", data.Entries[0].Type)
+	assert.Equal(t, "what%3F%23.txt", data.Entries[1].URL)
+	assert.Equal(t, "F", data.Entries[1].Type)
+}
+
+func TestListingJSON(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("servant"), 0o644))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "file.txt"), modTime, modTime))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+
+	tt := []struct {
+		name   string
+		target string
+		accept string
+		json   bool
+	}{
+		{"format parameter", "/?format=json&sort=name&order=desc", "", true},
+		{"accept header", "/?sort=name&order=desc", "text/html;q=0.9, application/json", true},
+		{"html", "/?sort=name&order=desc", "text/html", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Values("Vary"), "Accept")
+			if !tc.json {
+				assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+				return
+			}
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+			var listing struct {
+				Path    string           `json:"path"`
+				Sort    string           `json:"sort"`
+				Order   string           `json:"order"`
+				Entries []map[string]any `json:"entries"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+			assert.Equal(t, "/", listing.Path)
+			assert.Equal(t, sortName, listing.Sort)
+			assert.Equal(t, orderDesc, listing.Order)
+			if assert.Len(t, listing.Entries, 3) {
+				assert.Equal(t, "sub/", listing.Entries[0]["name"])
+				assert.Equal(t, true, listing.Entries[0]["isDir"])
+				assert.Equal(t, map[string]any{
+					"name":     "file.txt",
+					"url":      "file.txt",
+					"isDir":    false,
+					"type":     "F",
+					"size":     float64(7),
+					"modified": modTime.Format(time.RFC3339Nano),
+				}, listing.Entries[1])
+				// The checksums of the directory are listed too
+				assert.Equal(t, sumsFile, listing.Entries[2]["name"])
+			}
+		})
+	}
+}
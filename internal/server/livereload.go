@@ -0,0 +1,221 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// internalPrefix is reserved for the endpoints of servant itself.
+	internalPrefix = "/_servant/"
+	liveReloadPath = internalPrefix + "livereload"
+
+	liveReloadDebounce = 100 * time.Millisecond
+
+	eventReload = "reload"
+	eventCSS    = "css"
+)
+
+const liveReloadScript = `<script>
+(function () {
+	var source = new EventSource("` + liveReloadPath + `");
+	source.addEventListener("` + eventReload + `", function () {
+		location.reload();
+	});
+	source.addEventListener("` + eventCSS + `", function () {
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+			var url = new URL(link.href);
+			url.searchParams.set("_servant", Date.now());
+			link.href = url.toString();
+		});
+	});
+})();
+</script>
+`
+
+// liveReload watches the served tree and notifies the connected browsers
+// through server-sent events. Changes touching only stylesheets are sent as
+// a css event, so pages can swap them without a full reload.
+type liveReload struct {
+	root    string
+	script  string
+	ignore  *ignoreRules
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+}
+
+// enableLiveReload starts watching the served path. Failing to do so is
+// not fatal, files are still served without the reload script.
+func (fh *fileHandler) enableLiveReload() {
+	live, err := newLiveReload(fh.config.Path, fh.config.Prefix, fh.ignore)
+	if err != nil {
+		log.Warn("Unable to start live reload", "error", err)
+		return
+	}
+	log.Debug("Live reload enabled", "path", fh.config.Path)
+	fh.live = live
+}
+
+func newLiveReload(root, prefix string, ignore *ignoreRules) (*liveReload, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	lr := &liveReload{
+		root:    root,
+		script:  strings.Replace(liveReloadScript, liveReloadPath, prefix+liveReloadPath, 1),
+		ignore:  ignore,
+		watcher: watcher,
+		clients: map[chan string]struct{}{},
+	}
+	if err = lr.watch(root); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	go lr.run()
+	return lr, nil
+}
+
+// watch adds dir and all its subdirectories to the watcher,
+// as fsnotify doesn't watch recursively.
+func (lr *liveReload) watch(dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != lr.root && lr.ignored(p, true) {
+			return filepath.SkipDir
+		}
+		return lr.watcher.Add(p)
+	})
+}
+
+func (lr *liveReload) ignored(p string, isDir bool) bool {
+	rel, err := filepath.Rel(lr.root, p)
+	if err != nil {
+		return false
+	}
+	return lr.ignore.match(filepath.ToSlash(rel), isDir)
+}
+
+func (lr *liveReload) run() {
+	var timer <-chan time.Time
+	cssOnly := true
+	for {
+		select {
+		case event, ok := <-lr.watcher.Events:
+			if !ok {
+				return
+			}
+			info, err := os.Stat(event.Name)
+			isDir := err == nil && info.IsDir()
+			if event.Op == fsnotify.Chmod || lr.ignored(event.Name, isDir) {
+				continue
+			}
+			if event.Has(fsnotify.Create) && isDir {
+				_ = lr.watch(event.Name)
+			}
+			if !strings.EqualFold(filepath.Ext(event.Name), ".css") {
+				cssOnly = false
+			}
+			// editors usually write a file in several steps, so wait
+			// until things calm down before notifying the browsers
+			timer = time.After(liveReloadDebounce)
+		case <-timer:
+			event := eventReload
+			if cssOnly {
+				event = eventCSS
+			}
+			log.Debug("Live reload", "event", event)
+			lr.broadcast(event)
+			timer, cssOnly = nil, true
+		case err, ok := <-lr.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("Error watching files", "error", err)
+		}
+	}
+}
+
+func (lr *liveReload) broadcast(event string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- event:
+		default: // the client is lagging behind, it will reload anyway
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events, which keeps at most
+// one pending, and the function to stop receiving them.
+func (lr *liveReload) subscribe() (<-chan string, func()) {
+	client := make(chan string, 1)
+	lr.mu.Lock()
+	lr.clients[client] = struct{}{}
+	lr.mu.Unlock()
+	return client, func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}
+}
+
+func (lr *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, unsubscribe := lr.subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Warn("Live reload requires a streaming connection", "error", err)
+		return
+	}
+	for {
+		select {
+		case event := <-client:
+			wrapFprintf(w, "event: %s\ndata: %d\n\n", event, time.Now().UnixMilli())
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// inject returns the HTML document read from content with the live reload
+// script added before the closing body tag, or at the end if there is none.
+func (lr *liveReload) inject(content io.Reader) (io.ReadSeeker, int64, error) {
+	page, err := io.ReadAll(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	var buf bytes.Buffer
+	if i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>")); i >= 0 {
+		buf.Write(page[:i])
+		buf.WriteString(lr.script)
+		buf.Write(page[i:])
+	} else {
+		buf.Write(page)
+		buf.WriteString(lr.script)
+	}
+	return bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil
+}
@@ -0,0 +1,100 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveReloadInject(t *testing.T) {
+	lr := &liveReload{script: "<script></script>"}
+	tt := []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{"before the closing body", "<html><body>page</body></html>", "<html><body>page<script></script></body></html>"},
+		{"uppercase body", "<HTML><BODY>page</BODY></HTML>", "<HTML><BODY>page<script></script></BODY></HTML>"},
+		{"last closing body", "<body><pre></body></pre></body>", "<body><pre></body></pre><script></script></body>"},
+		{"without body", "<p>fragment</p>", "<p>fragment</p><script></script>"},
+		{"empty", "", "<script></script>"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			page, size, err := lr.inject(strings.NewReader(tc.page))
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(page)
+			assert.Equal(t, tc.expected, string(content))
+			assert.Equal(t, int64(len(tc.expected)), size)
+		})
+	}
+}
+
+func TestLiveReloadPages(t *testing.T) {
+	dir := t.TempDir()
+	page := "<html><body>" + strings.Repeat("servant ", 200) + "</body></html>"
+	modTime := time.Now().Add(-time.Hour)
+	for name, content := range map[string]string{"index.html": page, "index.html.gz": "precompressed", "fragment.html": "<p>fragment</p>", "app.js": "console.log(1)"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+
+	tt := []struct {
+		name     string
+		config   Configuration
+		target   string
+		encoding string
+		rangeHdr string
+		injected bool
+	}{
+		{"page", Configuration{}, "/", "", "", true},
+		{"page without body", Configuration{}, "/fragment.html", "", "", true},
+		{"not a page", Configuration{}, "/app.js", "", "", false},
+		{"range", Configuration{}, "/", "", "bytes=0-99", false},
+		{"precompressed sidecars are skipped", Configuration{}, "/", "gzip", "", true},
+		{"compressed on the fly", Configuration{Compress: true}, "/", "gzip", "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			config.Path, config.LiveReload = dir, true
+			handler := FileServer(http.Dir(dir), config)
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.encoding != "" {
+				r.Header.Set("Accept-Encoding", tc.encoding)
+			}
+			if tc.rangeHdr != "" {
+				r.Header.Set("Range", tc.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Less(t, w.Code, http.StatusMultipleChoices)
+
+			var body io.Reader = w.Body
+			if w.Header().Get("Content-Encoding") == "gzip" {
+				assert.True(t, config.Compress)
+				gr, err := gzip.NewReader(w.Body)
+				assert.NoError(t, err)
+				body = gr
+			} else {
+				assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+			}
+			content, err := io.ReadAll(body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.injected, strings.Contains(string(content), liveReloadPath))
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const rawParam = "raw"
+
+const markdownCss = `
+	<style>
+		.markdown {
+			max-width: 900px;
+			line-height: 1.6;
+		}
+
+		.markdown h1, .markdown h2 {
+			border-bottom: 1px solid gainsboro;
+			padding-bottom: 6px;
+		}
+
+		.markdown a.anchor {
+			margin-left: 8px;
+			text-decoration: none;
+			visibility: hidden;
+		}
+
+		.markdown :hover > a.anchor {
+			visibility: visible;
+		}
+
+		.markdown code {
+			font-family: 'Courier New';
+			background-color: whitesmoke;
+			padding: 2px 4px;
+			border-radius: 4px;
+		}
+
+		.markdown pre {
+			background-color: whitesmoke;
+			padding: 12px;
+			border-radius: 6px;
+			overflow-x: auto;
+		}
+
+		.markdown pre code {
+			padding: 0;
+		}
+
+		.markdown blockquote {
+			margin-left: 0;
+			padding-left: 16px;
+			border-left: 4px solid gainsboro;
+		}
+
+		.markdown table {
+			border-collapse: collapse;
+		}
+
+		.markdown table tr th, .markdown table tr td {
+			width: auto;
+			border: 1px solid gainsboro;
+			padding: 6px 12px;
+			font-family: inherit;
+			font-weight: normal;
+			text-align: left;
+		}
+
+		.markdown table tr th {
+			font-weight: 600;
+		}
+
+		.markdown img {
+			max-width: 100%;
+		}
+
+		.readme {
+			margin-top: 40px;
+			padding-top: 20px;
+			border-top: 1px solid gainsboro;
+		}
+	</style>
+	`
+
+// markdownAnchors links every heading to itself, like code hosts do.
+const markdownAnchors = `
+	<script>
+		document.addEventListener("DOMContentLoaded", function () {
+			document.querySelectorAll(".markdown [id]").forEach(function (heading) {
+				if (/^H[1-6]$/.test(heading.tagName)) {
+					var anchor = document.createElement("a");
+					anchor.className = "anchor";
+					anchor.href = "#" + heading.id;
+					anchor.textContent = "#";
+					heading.appendChild(anchor);
+				}
+			});
+		});
+	</script>
+	`
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	// served files are trusted as much as any HTML file in the same tree
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	default:
+		return false
+	}
+}
+
+// wantsHTML reports whether r comes from a browser navigating to a page,
+// rather than from a script or a command line client fetching a file.
+func wantsHTML(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(v)
+		if mediaType == "text/html" {
+			return true
+		}
+	}
+	return false
+}
+
+func renderMarkdown(content io.Reader) ([]byte, error) {
+	source, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = markdown.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serveMarkdown renders the Markdown file f as an HTML page for browsers and
+// reports whether it did. ?raw=1 always gets the original file, and
+// ?view=source its highlighted source.
+func (fh *fileHandler) serveMarkdown(w http.ResponseWriter, r *http.Request, d fs.FileInfo, f io.Reader) bool {
+	if !isMarkdown(d.Name()) {
+		return false
+	}
+	addVary(w.Header(), "Accept")
+	query := r.URL.Query()
+	if !wantsHTML(r) || query.Get(rawParam) != "" || query.Get(viewParam) == viewSource {
+		return false
+	}
+	if checkIfModifiedSince(r, d.ModTime()) == condFalse {
+		writeNotModified(w)
+		return true
+	}
+	body, err := renderMarkdown(f)
+	if err != nil {
+		logf(r, "http: error rendering markdown: %v", err)
+		fh.writeError(w, r, "Error rendering markdown", http.StatusInternalServerError)
+		return true
+	}
+	setLastModified(w, d.ModTime())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wrapFprintf(w, "<title>%s</title>\n", htmlReplacer.Replace(d.Name()))
+	wrapFprintf(w, "<a href=\"?%s=1\">View raw</a>\n", rawParam)
+	wrapFprintf(w, "<article class=\"markdown\">\n%s</article>\n", body)
+	_, _ = io.WriteString(w, fileServerCss)
+	_, _ = io.WriteString(w, markdownCss)
+	_, _ = io.WriteString(w, markdownAnchors)
+	if fh.live != nil {
+		_, _ = io.WriteString(w, fh.live.script)
+	}
+	return true
+}
+
+// readme returns the rendered README of the directory name, if any.
+func (fh *fileHandler) readme(name string, dirs anyDirs) []byte {
+	for i, n := 0, dirs.len(); i < n; i++ {
+		if dirs.isDir(i) || !strings.HasPrefix(strings.ToLower(dirs.name(i)), "readme.") || !isMarkdown(dirs.name(i)) {
+			continue
+		}
+		f, err := fh.root.Open(path.Join(name, dirs.name(i)))
+		if err != nil {
+			continue
+		}
+		body, err := renderMarkdown(f)
+		_ = f.Close()
+		if err == nil {
+			return body
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeMarkdown(t *testing.T) {
+	const browser = "text/html,application/xhtml+xml,*/*;q=0.8"
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"guide.md":       "# Guide\n\nSome *text*.\n",
+		"docs/README.md": "# Docs\n\nRead me first.\n",
+		"docs/a.txt":     "a",
+		"other/b.txt":    "b",
+	})
+	modTime := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "guide.md"), modTime, modTime))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+
+	tt := []struct {
+		name     string
+		url      string
+		header   http.Header
+		status   int
+		vary     string
+		contains []string
+		missing  []string
+	}{
+		{
+			name:     "rendered for browsers",
+			url:      "/guide.md",
+			header:   http.Header{"Accept": {browser}},
+			status:   http.StatusOK,
+			vary:     "Accept",
+			contains: []string{`<h1 id="guide">Guide</h1>`, "<em>text</em>", `<a href="?raw=1">View raw</a>`},
+		},
+		{
+			name:     "raw file for browsers",
+			url:      "/guide.md?raw=1",
+			header:   http.Header{"Accept": {browser}},
+			status:   http.StatusOK,
+			vary:     "Accept",
+			contains: []string{"# Guide"},
+			missing:  []string{"<h1"},
+		},
+		{
+			name:     "original file for command line clients",
+			url:      "/guide.md",
+			header:   http.Header{"Accept": {"*/*"}},
+			status:   http.StatusOK,
+			vary:     "Accept",
+			contains: []string{"# Guide"},
+			missing:  []string{"<h1"},
+		},
+		{
+			name: "not modified since",
+			url:  "/guide.md",
+			header: http.Header{
+				"Accept":            {browser},
+				"If-Modified-Since": {modTime.Add(time.Hour).Format(http.TimeFormat)},
+			},
+			status: http.StatusNotModified,
+			vary:   "Accept",
+		},
+		{
+			name: "modified since",
+			url:  "/guide.md",
+			header: http.Header{
+				"Accept":            {browser},
+				"If-Modified-Since": {modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			},
+			status:   http.StatusOK,
+			vary:     "Accept",
+			contains: []string{`<h1 id="guide">Guide</h1>`},
+		},
+		{
+			name:     "readme in the listing",
+			url:      "/docs/",
+			header:   http.Header{"Accept": {browser}},
+			status:   http.StatusOK,
+			contains: []string{`<article class="markdown readme">`, `<h1 id="docs">Docs</h1>`, "Read me first."},
+		},
+		{
+			name:    "listing without readme",
+			url:     "/other/",
+			header:  http.Header{"Accept": {browser}},
+			status:  http.StatusOK,
+			missing: []string{`<article class="markdown readme">`},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			if tc.vary != "" {
+				assert.Equal(t, tc.vary, w.Header().Get("Vary"))
+			}
+			for _, s := range tc.contains {
+				assert.Contains(t, w.Body.String(), s)
+			}
+			for _, s := range tc.missing {
+				assert.NotContains(t, w.Body.String(), s)
+			}
+		})
+	}
+}
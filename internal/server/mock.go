@@ -0,0 +1,454 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"github.com/planta7/servant/internal/network"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	mockAnyMethod  = "ANY"
+	mockMetaSuffix = ".meta.json"
+	mockMaxBody    = 10 << 20
+)
+
+var mockMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	mockAnyMethod:      true,
+}
+
+// mockFile is a fixture, or the metadata of one, named after the method and
+// the route: users/GET.json, GET_users.json or users/GET_{id}.2.json, the
+// second response of a sequence. Fixtures and their metadata share the key,
+// and the ones of a sequence also share the route key.
+type mockFile struct {
+	method   string
+	segments []string
+	seq      int
+	key      string
+	routeKey string
+}
+
+// parseMockFile parses the slash-separated name of a file of the fixtures,
+// and reports false if it is not one.
+func parseMockFile(name string) (mockFile, bool) {
+	dir, base := path.Split(name)
+	stem, isMeta := strings.CutSuffix(base, mockMetaSuffix)
+	if !isMeta {
+		stem = strings.TrimSuffix(base, path.Ext(base))
+	}
+	f := mockFile{key: dir + stem}
+	if i := strings.LastIndex(stem, "."); i >= 0 {
+		if seq, err := strconv.Atoi(stem[i+1:]); err == nil && seq > 0 {
+			f.seq, stem = seq, stem[:i]
+		}
+	}
+	f.routeKey = dir + stem
+	method, rest, _ := strings.Cut(stem, "_")
+	if !mockMethods[method] {
+		return f, false
+	}
+	f.method = method
+	for _, segment := range strings.Split(dir, "/") {
+		if segment != "" {
+			f.segments = append(f.segments, segment)
+		}
+	}
+	if rest != "" {
+		f.segments = append(f.segments, rest)
+	}
+	return f, true
+}
+
+// mockRoute is a method and path, with {name} parameters, and the fixtures
+// answering it in order.
+type mockRoute struct {
+	method    string
+	segments  []string
+	responses []mockResponse
+}
+
+type mockResponse struct {
+	seq  int
+	file string
+	meta string
+}
+
+func (mr *mockRoute) String() string {
+	return mr.method + " /" + strings.Join(mr.segments, "/")
+}
+
+// match returns the parameters of upath if it is a path of the route.
+func (mr *mockRoute) match(upath string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(upath, "/"), "/")
+	if upath == "/" {
+		segments = nil
+	}
+	if len(segments) != len(mr.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range mr.segments {
+		if name, ok := mockParam(segment); ok {
+			params[name] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func mockParam(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// moreSpecific reports whether mr goes before other, as the first literal
+// segment where the other route has a parameter wins.
+func (mr *mockRoute) moreSpecific(other *mockRoute) bool {
+	for i := 0; i < len(mr.segments) && i < len(other.segments); i++ {
+		_, param := mockParam(mr.segments[i])
+		_, otherParam := mockParam(other.segments[i])
+		if param != otherParam {
+			return otherParam
+		}
+	}
+	return false
+}
+
+// loadMockRoutes reads the routes of the fixtures in fsys, the most
+// specific first. Hidden files are skipped.
+func loadMockRoutes(fsys http.FileSystem) ([]*mockRoute, error) {
+	routes := map[string]*mockRoute{}
+	metas := map[string]string{}
+	err := walkMockFiles(fsys, "", func(name string) {
+		f, ok := parseMockFile(name)
+		if !ok {
+			return
+		}
+		if strings.HasSuffix(name, mockMetaSuffix) {
+			metas[f.key] = name
+			return
+		}
+		route := &mockRoute{method: f.method, segments: f.segments}
+		if existing, ok := routes[route.String()]; ok {
+			route = existing
+		}
+		route.responses = append(route.responses, mockResponse{seq: f.seq, file: name})
+		routes[route.String()] = route
+	})
+	if err != nil {
+		return nil, err
+	}
+	var sorted []*mockRoute
+	for _, route := range routes {
+		for i, response := range route.responses {
+			f, _ := parseMockFile(response.file)
+			if meta, ok := metas[f.key]; ok {
+				route.responses[i].meta = meta
+			} else {
+				route.responses[i].meta = metas[f.routeKey]
+			}
+		}
+		sort.Slice(route.responses, func(i, j int) bool { return route.responses[i].seq < route.responses[j].seq })
+		sorted = append(sorted, route)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].moreSpecific(sorted[j]) != sorted[j].moreSpecific(sorted[i]) {
+			return sorted[i].moreSpecific(sorted[j])
+		}
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted, nil
+}
+
+// walkMockFiles calls fn with the '/'-separated names of the files in dir
+// and its subdirectories, except the hidden ones. Subdirectories that can't
+// be read are skipped.
+func walkMockFiles(fsys http.FileSystem, dir string, fn func(name string)) error {
+	f, err := fsys.Open("/" + dir)
+	if err != nil {
+		return err
+	}
+	infos, err := f.Readdir(-1)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		name := path.Join(dir, info.Name())
+		if !info.IsDir() {
+			fn(name)
+			continue
+		}
+		if err := walkMockFiles(fsys, name, fn); err != nil {
+			log.Warn("Skipping fixtures", "path", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// readMockFile returns the content of the '/'-separated name in fsys.
+func readMockFile(fsys http.FileSystem, name string) ([]byte, error) {
+	f, err := fsys.Open("/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// mockMeta is the optional metadata of a fixture, in a file with the same
+// name ending in .meta.json. The metadata of GET_users.json also applies to
+// the sequence GET_users.1.json, GET_users.2.json... unless they have their
+// own.
+type mockMeta struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Latency string            `json:"latency"`
+}
+
+// mockRequest is the data of the fixtures with templates. Query has the
+// first value of each parameter.
+type mockRequest struct {
+	Method string
+	Path   string
+	Params map[string]string
+	Query  map[string]string
+	Header http.Header
+	Body   any
+}
+
+var mockFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// mockHandler answers the requests with the fixtures of a directory, read
+// on each request, so they can be changed while the server runs. The routes
+// are read again when the directory changes, or on each request if it
+// can't be watched. Other files are served as usual.
+type mockHandler struct {
+	root    string
+	fsys    http.FileSystem
+	files   http.Handler
+	changes <-chan string
+	mu      sync.Mutex
+	routes  []*mockRoute
+	loaded  bool
+	served  map[string]int
+}
+
+func MockServer(config Configuration) Handler {
+	mh := &mockHandler{
+		root:   config.Path,
+		fsys:   symlinkFileSystem{FileSystem: http.Dir(config.Path), policy: newSymlinkPolicy(config.Path, config.Symlinks)},
+		files:  localFiles(config),
+		served: map[string]int{},
+	}
+	if live, err := newLiveReload(config.Path, "", newIgnoreRules([]string{".*"})); err == nil {
+		mh.changes, _ = live.subscribe()
+	} else {
+		log.Warn("Unable to watch fixtures, reading them on each request", "error", err)
+	}
+	return mh
+}
+
+// loadRoutes returns the routes of the fixtures, read again if they
+// changed.
+func (mh *mockHandler) loadRoutes() ([]*mockRoute, error) {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	select {
+	case <-mh.changes:
+		mh.loaded = false
+	default:
+	}
+	if mh.loaded {
+		return mh.routes, nil
+	}
+	routes, err := loadMockRoutes(mh.fsys)
+	if err != nil {
+		return nil, err
+	}
+	mh.routes, mh.loaded = routes, mh.changes != nil
+	return routes, nil
+}
+
+func (mh *mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+	routes, err := mh.loadRoutes()
+	if err != nil {
+		log.Error("Error reading fixtures", "path", mh.root, "error", err)
+		http.Error(w, "Error reading fixtures", http.StatusInternalServerError)
+		return
+	}
+	route, params, allowed := findMockRoute(routes, r.Method, upath)
+	if route == nil {
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		mh.files.ServeHTTP(w, r)
+		return
+	}
+	if err := mh.serve(w, r, mh.next(route), params); err != nil {
+		log.Warn("Error serving fixture", "route", route.String(), "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// findMockRoute returns the route of method and upath, preferring the ones
+// of the method over ANY, and HEAD is answered by GET. Without a route, it
+// returns the methods of upath, if any.
+func findMockRoute(routes []*mockRoute, method, upath string) (*mockRoute, map[string]string, []string) {
+	methods := []string{method, mockAnyMethod}
+	if method == http.MethodHead {
+		methods = []string{method, http.MethodGet, mockAnyMethod}
+	}
+	for _, m := range methods {
+		for _, route := range routes {
+			if route.method != m {
+				continue
+			}
+			if params, ok := route.match(upath); ok {
+				return route, params, nil
+			}
+		}
+	}
+	var allowed []string
+	for _, route := range routes {
+		if _, ok := route.match(upath); ok {
+			allowed = append(allowed, route.method)
+		}
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+// next returns the response of the route for this request: the fixtures of
+// a sequence are served in order, and then the last one is repeated.
+func (mh *mockHandler) next(route *mockRoute) mockResponse {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	i := mh.served[route.String()]
+	mh.served[route.String()] = i + 1
+	if i >= len(route.responses) {
+		i = len(route.responses) - 1
+	}
+	return route.responses[i]
+}
+
+func (mh *mockHandler) serve(w http.ResponseWriter, r *http.Request, response mockResponse, params map[string]string) error {
+	body, err := readMockFile(mh.fsys, response.file)
+	if err != nil {
+		return err
+	}
+	var meta mockMeta
+	if response.meta != "" {
+		data, err := readMockFile(mh.fsys, response.meta)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("invalid metadata %s: %w", response.meta, err)
+		}
+	}
+	if meta.Latency != "" {
+		latency, err := time.ParseDuration(meta.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid latency in %s: %w", response.meta, err)
+		}
+		sleep(r.Context(), latency)
+	}
+	if bytes.Contains(body, []byte("{{")) {
+		if body, err = renderMock(response.file, body, newMockRequest(r, params)); err != nil {
+			return err
+		}
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(response.file)); ctype != "" {
+		w.Header().Set(network.ContentType, ctype)
+	}
+	for name, value := range meta.Headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(network.ContentLength, strconv.Itoa(len(body)))
+	status := meta.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+	return nil
+}
+
+// newMockRequest returns the template data of r. The body is decoded if it
+// is JSON, and is left as a string otherwise.
+func newMockRequest(r *http.Request, params map[string]string) mockRequest {
+	data := mockRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Params: params,
+		Query:  map[string]string{},
+		Header: r.Header,
+	}
+	for name, values := range r.URL.Query() {
+		data.Query[name] = values[0]
+	}
+	if r.Body == nil {
+		return data
+	}
+	body, _ := io.ReadAll(io.LimitReader(r.Body, mockMaxBody))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := json.Unmarshal(body, &data.Body); err != nil {
+		data.Body = string(body)
+	}
+	return data
+}
+
+func renderMock(name string, body []byte, data mockRequest) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(mockFuncs).Option("missingkey=zero").Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
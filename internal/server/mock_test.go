@@ -0,0 +1,184 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMockFile(t *testing.T) {
+	tt := []struct {
+		name     string
+		file     string
+		expected mockFile
+		ok       bool
+	}{
+		{
+			"method and name",
+			"GET_users.json",
+			mockFile{method: "GET", segments: []string{"users"}, key: "GET_users", routeKey: "GET_users"},
+			true,
+		},
+		{
+			"method in a directory",
+			"users/{id}/PUT.json",
+			mockFile{method: "PUT", segments: []string{"users", "{id}"}, key: "users/{id}/PUT", routeKey: "users/{id}/PUT"},
+			true,
+		},
+		{
+			"sequence",
+			"api/GET_jobs.2.json",
+			mockFile{method: "GET", segments: []string{"api", "jobs"}, seq: 2, key: "api/GET_jobs.2", routeKey: "api/GET_jobs"},
+			true,
+		},
+		{
+			"metadata",
+			"POST_users.meta.json",
+			mockFile{method: "POST", segments: []string{"users"}, key: "POST_users", routeKey: "POST_users"},
+			true,
+		},
+		{"root", "ANY.json", mockFile{method: "ANY", key: "ANY", routeKey: "ANY"}, true},
+		{"not a method", "README.md", mockFile{}, false},
+		{"lowercase method", "get_users.json", mockFile{}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := parseMockFile(tc.file)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.expected, f)
+			}
+		})
+	}
+}
+
+func TestMockServer(t *testing.T) {
+	dir := t.TempDir()
+	fixtures := map[string]string{
+		"GET_users.json":             `[{"id":1}]`,
+		"users/{id}/GET.json":        `{"id":"{{.Params.id}}","page":"{{.Query.page}}"}`,
+		"users/GET_me.json":          `{"id":"me"}`,
+		"POST_users.json":            `{{json .Body}}`,
+		"POST_users.meta.json":       `{"status":201,"headers":{"Location":"/users/2"}}`,
+		"GET_jobs.1.json":            `pending`,
+		"GET_jobs.2.json":            `done`,
+		"GET_jobs.2.meta.json":       `{"status":202}`,
+		"ANY_echo.txt":               `{{.Method}} {{.Header.Get "X-Id"}}`,
+		"static/logo.txt":            `logo`,
+		".hidden/GET_secret.json":    `{}`,
+		"GET_broken.json":            `{{.Nope`,
+		"GET_invalid-meta.json":      `{}`,
+		"GET_invalid-meta.meta.json": `{`,
+	}
+	for name, content := range fixtures {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+	handler := MockServer(Configuration{Path: dir})
+
+	tt := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		status   int
+		expected string
+		header   string
+		value    string
+	}{
+		{"list", http.MethodGet, "/users", "", http.StatusOK, `[{"id":1}]`, "Content-Type", "application/json"},
+		{"params and query", http.MethodGet, "/users/7?page=2", "", http.StatusOK, `{"id":"7","page":"2"}`, "", ""},
+		{"literal before parameter", http.MethodGet, "/users/me", "", http.StatusOK, `{"id":"me"}`, "", ""},
+		{"metadata and body", http.MethodPost, "/users", `{"name":"ann"}`, http.StatusCreated, `{"name":"ann"}`, "Location", "/users/2"},
+		{"sequence first", http.MethodGet, "/jobs", "", http.StatusOK, "pending", "", ""},
+		{"sequence second", http.MethodGet, "/jobs", "", http.StatusAccepted, "done", "", ""},
+		{"sequence repeats the last", http.MethodGet, "/jobs", "", http.StatusAccepted, "done", "", ""},
+		{"any method", http.MethodDelete, "/echo", "", http.StatusOK, "DELETE 42", "", ""},
+		{"method not allowed", http.MethodDelete, "/users", "", http.StatusMethodNotAllowed, "Method Not Allowed\n", "Allow", "GET, POST"},
+		{"head", http.MethodHead, "/users", "", http.StatusOK, "", "Content-Length", "10"},
+		{"static file", http.MethodGet, "/static/logo.txt", "", http.StatusOK, "logo", "", ""},
+		{"hidden fixture", http.MethodGet, "/secret", "", http.StatusNotFound, "404 page not found\n", "", ""},
+		{"invalid template", http.MethodGet, "/broken", "", http.StatusInternalServerError, "Internal Server Error\n", "", ""},
+		{"invalid metadata", http.MethodGet, "/invalid-meta", "", http.StatusInternalServerError, "Internal Server Error\n", "", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			r.Header.Set("X-Id", "42")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.expected, w.Body.String())
+			if tc.header != "" {
+				assert.Equal(t, tc.value, w.Header().Get(tc.header))
+			}
+		})
+	}
+}
+
+func TestMockServerChanges(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "GET_users.json"), []byte(`[]`), 0o644))
+	handler := MockServer(Configuration{Path: dir})
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusOK, get("/users").Code)
+	assert.Equal(t, http.StatusNotFound, get("/jobs").Code)
+
+	// New fixtures are picked up once the change is noticed
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "GET_jobs.json"), []byte(`[]`), 0o644))
+	assert.Eventually(t, func() bool { return get("/jobs").Code == http.StatusOK }, 2*time.Second, 20*time.Millisecond)
+
+	// Changes to the content are served right away
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "GET_users.json"), []byte(`[1]`), 0o644))
+	assert.Equal(t, `[1]`, get("/users").Body.String())
+}
+
+func TestMockServerSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "secret.json"), []byte(`{"secret":true}`), 0o644))
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "users.json"), []byte(`[]`), 0o644))
+	if os.Symlink(filepath.Join(outside, "secret.json"), filepath.Join(dir, "GET_secret.json")) != nil ||
+		os.Symlink("users.json", filepath.Join(dir, "GET_users.json")) != nil ||
+		os.Symlink(outside, filepath.Join(dir, "outside")) != nil {
+		t.Skip("symbolic links are not supported")
+	}
+
+	tt := []struct {
+		name     string
+		symlinks string
+		target   string
+		status   int
+	}{
+		{"link inside the root", SymlinksRoot, "/users", http.StatusOK},
+		{"link outside the root", SymlinksRoot, "/secret", http.StatusNotFound},
+		{"file in a directory outside the root", SymlinksRoot, "/outside/secret.json", http.StatusNotFound},
+		{"links denied", SymlinksDeny, "/users", http.StatusNotFound},
+		{"links followed", SymlinksFollow, "/secret", http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := MockServer(Configuration{Path: dir, Symlinks: tc.symlinks})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.symlinks == SymlinksFollow, strings.Contains(w.Body.String(), "secret"))
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	mountReadOnly  = "ro"
+	mountReadWrite = "rw"
+	mountNoListing = "nolist"
+	mountListing   = "list"
+	mountUpload    = "upload"
+	mountGitRef    = "ref="
+)
+
+// Mount is a directory or archive served under a URL prefix, with its own
+// settings.
+type Mount struct {
+	Path      string
+	Prefix    string
+	ReadOnly  bool
+	NoListing bool
+	Upload    bool
+	GitRef    string
+}
+
+// ParseMounts parses the mounts given as path[:prefix[:options]], where
+// options is a comma-separated list of ro, rw, nolist, list, upload and
+// ref=<git revision>. Settings not given are taken from config. Without prefix, a single path
+// is mounted at the root and several ones at their base names.
+func ParseMounts(specs []string, config Configuration) ([]Mount, error) {
+	var mounts []Mount
+	prefixes := map[string]bool{}
+	for _, spec := range specs {
+		m, err := parseMount(spec, config)
+		if err != nil {
+			return nil, err
+		}
+		if m.Prefix == "" {
+			m.Prefix = "/"
+			if len(specs) > 1 {
+				abs, _ := filepath.Abs(m.Path)
+				m.Prefix = path.Clean("/" + filepath.Base(abs))
+			}
+		}
+		if prefixes[m.Prefix] {
+			return nil, fmt.Errorf("mount %q: prefix %s is already mounted", spec, m.Prefix)
+		}
+		prefixes[m.Prefix] = true
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+func parseMount(spec string, config Configuration) (Mount, error) {
+	m := Mount{
+		Path:      spec,
+		ReadOnly:  config.ReadOnly,
+		NoListing: config.NoListing,
+		Upload:    config.Upload,
+		GitRef:    config.GitRef,
+	}
+	if p, rest, ok := cutMountPath(spec); ok {
+		m.Path = p
+		parts := strings.SplitN(rest, ":", 2)
+		if parts[0] != "" {
+			m.Prefix = path.Clean("/" + parts[0])
+		}
+		if len(parts) == 2 {
+			for _, option := range strings.Split(parts[1], ",") {
+				option = strings.TrimSpace(option)
+				if strings.HasPrefix(option, mountGitRef) {
+					m.GitRef = strings.TrimPrefix(option, mountGitRef)
+					continue
+				}
+				switch option {
+				case mountReadOnly:
+					m.ReadOnly = true
+				case mountReadWrite:
+					m.ReadOnly = false
+				case mountNoListing:
+					m.NoListing = true
+				case mountListing:
+					m.NoListing = false
+				case mountUpload:
+					m.Upload = true
+				case "":
+				default:
+					return m, fmt.Errorf("mount %q: unknown option %q", spec, option)
+				}
+			}
+		}
+	}
+	if info, err := os.Stat(m.Path); err != nil {
+		return m, fmt.Errorf("mount %q: %w", spec, err)
+	} else if !info.IsDir() && (archiveExtension(m.Path) == "" || m.GitRef != "") {
+		return m, fmt.Errorf("mount %q: %s is neither a directory nor an archive", spec, m.Path)
+	}
+	return m, nil
+}
+
+// cutMountPath slices spec around the colon after the path, keeping Windows
+// drive letters (C:\...) in the path, and reports whether there is one.
+func cutMountPath(spec string) (string, string, bool) {
+	offset := 0
+	if len(spec) > 2 && isLetter(spec[0]) && spec[1] == ':' && (spec[2] == '\\' || spec[2] == '/') {
+		offset = 2
+	}
+	if i := strings.Index(spec[offset:], ":"); i >= 0 {
+		return spec[:offset+i], spec[offset+i+1:], true
+	}
+	return spec, "", false
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// mountConfig returns the configuration of the file server of m.
+func mountConfig(config Configuration, m Mount) Configuration {
+	config.Path = m.Path
+	config.Prefix = strings.TrimSuffix(m.Prefix, "/")
+	config.ReadOnly = m.ReadOnly
+	config.NoListing = m.NoListing
+	config.Upload = m.Upload
+	config.GitRef = m.GitRef
+	config.Mounts = nil
+	return config
+}
+
+// localFiles returns the handler of config.Path: a directory, an archive or
+// a git revision of a directory.
+func localFiles(config Configuration) Handler {
+	var root http.FileSystem
+	switch {
+	case config.GitRef != "":
+		fsys, err := openGitFS(config.Path, config.GitRef)
+		if err != nil {
+			log.Fatal("Error reading git revision", "path", config.Path, "ref", config.GitRef, "error", err)
+		}
+		log.Debug("Serving git revision", "path", config.Path, "ref", config.GitRef, "commit", fsys.commit.Hash)
+		root = fsys
+	case archiveExtension(config.Path) != "":
+		fsys, err := openArchiveFS(config.Path)
+		if err != nil {
+			log.Fatal("Error opening archive", "path", config.Path, "error", err)
+		}
+		root = http.FS(fsys)
+	default:
+		links := newSymlinkPolicy(config.Path, config.Symlinks)
+		var handler Handler = FileServer(symlinkFileSystem{FileSystem: http.Dir(config.Path), policy: links}, config)
+		if config.WebDAV {
+			handler = WebDAVServer(config, links, handler)
+		}
+		return handler
+	}
+	if config.WebDAV || config.LiveReload || config.Upload {
+		log.Warn("WebDAV, live reload and uploads are only available for directories", "path", config.Path)
+		config.LiveReload, config.Upload = false, false
+	}
+	return FileServer(root, config)
+}
+
+type mountRoute struct {
+	Mount
+	handler http.Handler
+}
+
+// mountHandler routes requests to the mount with the longest matching
+// prefix. Without a mount at the root, it lists the mounts there.
+type mountHandler struct {
+	config  Configuration
+	routes  []mountRoute
+	listing *template.Template
+}
+
+func MountServer(config Configuration) Handler {
+	mounts := config.Mounts
+	if len(mounts) == 0 {
+		mounts = []Mount{{
+			Path:      config.Path,
+			Prefix:    "/",
+			ReadOnly:  config.ReadOnly,
+			NoListing: config.NoListing,
+			Upload:    config.Upload,
+			GitRef:    config.GitRef,
+		}}
+	}
+	if len(mounts) == 1 && mounts[0].Prefix == "/" {
+		return localFiles(mountConfig(config, mounts[0]))
+	}
+
+	mh := &mountHandler{config: config, listing: loadListingTemplate(config)}
+	for _, m := range mounts {
+		var handler http.Handler = localFiles(mountConfig(config, m))
+		if m.Prefix != "/" {
+			handler = http.StripPrefix(m.Prefix, handler)
+		}
+		mh.routes = append(mh.routes, mountRoute{Mount: m, handler: handler})
+	}
+	sort.SliceStable(mh.routes, func(i, j int) bool { return len(mh.routes[i].Prefix) > len(mh.routes[j].Prefix) })
+	return mh
+}
+
+func (mh *mountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+	for _, route := range mh.routes {
+		if route.Prefix == "/" || strings.HasPrefix(upath+"/", route.Prefix+"/") {
+			if route.Prefix != "/" && r.URL.Path == route.Prefix {
+				localRedirect(w, r, path.Base(route.Prefix)+"/")
+				return
+			}
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	if upath != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	mh.index(w, r)
+}
+
+// index lists the mounts as the directories of the root.
+func (mh *mountHandler) index(w http.ResponseWriter, r *http.Request) {
+	var dirs mountDirs
+	for _, route := range mh.routes {
+		dirs = append(dirs, route.Mount)
+	}
+	data := newListingData(r, dirs, true)
+	data.Virtual = true
+	addVary(w.Header(), "Accept")
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	data.Styles = fileServerCss
+	var buf bytes.Buffer
+	if err := mh.listing.Execute(&buf, data); err != nil {
+		logf(r, "http: error rendering directory: %v", err)
+		http.Error(w, "Error rendering directory", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// mountDirs lists the mounts like the directories of a listing.
+type mountDirs []Mount
+
+func (d mountDirs) len() int             { return len(d) }
+func (d mountDirs) isDir(_ int) bool     { return true }
+func (d mountDirs) isRegular(_ int) bool { return false }
+func (d mountDirs) name(i int) string    { return strings.Trim(d[i].Prefix, "/") }
+func (d mountDirs) size(_ int) int64     { return 0 }
+func (d mountDirs) fType(_ int) string   { return "D" }
+func (d mountDirs) lastModified(i int) time.Time {
+	if info, err := os.Stat(d[i].Path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
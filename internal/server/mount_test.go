@@ -0,0 +1,145 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	build := filepath.Join(dir, "build")
+	assert.NoError(t, os.Mkdir(docs, 0o755))
+	assert.NoError(t, os.Mkdir(build, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), nil, 0o644))
+
+	tt := []struct {
+		name     string
+		specs    []string
+		config   Configuration
+		expected []Mount
+		wantErr  bool
+	}{
+		{
+			"no paths",
+			nil,
+			Configuration{},
+			nil,
+			false,
+		},
+		{
+			"single path at the root",
+			[]string{docs},
+			Configuration{},
+			[]Mount{{Path: docs, Prefix: "/"}},
+			false,
+		},
+		{
+			"several paths at their names",
+			[]string{docs, build},
+			Configuration{},
+			[]Mount{{Path: docs, Prefix: "/docs"}, {Path: build, Prefix: "/build"}},
+			false,
+		},
+		{
+			"explicit prefixes",
+			[]string{docs + ":/manual/", build + ":/"},
+			Configuration{},
+			[]Mount{{Path: docs, Prefix: "/manual"}, {Path: build, Prefix: "/"}},
+			false,
+		},
+		{
+			"options",
+			[]string{docs + ":/docs:ro,nolist,upload"},
+			Configuration{},
+			[]Mount{{Path: docs, Prefix: "/docs", ReadOnly: true, NoListing: true, Upload: true}},
+			false,
+		},
+		{
+			"options override the configuration",
+			[]string{docs + ":/docs:rw,list", build + ":/build"},
+			Configuration{ReadOnly: true, NoListing: true},
+			[]Mount{{Path: docs, Prefix: "/docs"}, {Path: build, Prefix: "/build", ReadOnly: true, NoListing: true}},
+			false,
+		},
+		{
+			"git revision",
+			[]string{docs + "::ref=v1.2.0,ro"},
+			Configuration{GitRef: "main"},
+			[]Mount{{Path: docs, Prefix: "/", ReadOnly: true, GitRef: "v1.2.0"}},
+			false,
+		},
+		{
+			"unknown option",
+			[]string{docs + ":/docs:rx"},
+			Configuration{},
+			nil,
+			true,
+		},
+		{
+			"duplicated prefix",
+			[]string{docs + ":/site", build + ":/site/"},
+			Configuration{},
+			nil,
+			true,
+		},
+		{
+			"missing path",
+			[]string{filepath.Join(dir, "missing")},
+			Configuration{},
+			nil,
+			true,
+		},
+		{
+			"not a directory",
+			[]string{filepath.Join(dir, "file.txt")},
+			Configuration{},
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ParseMounts(tc.specs, tc.config)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestCutMountPath(t *testing.T) {
+	tt := []struct {
+		name string
+		spec string
+		path string
+		rest string
+		ok   bool
+	}{
+		{"path only", "./docs", "./docs", "", false},
+		{"prefix", "./docs:/manual", "./docs", "/manual", true},
+		{"prefix and options", "./docs:/manual:ro", "./docs", "/manual:ro", true},
+		{"windows drive", `C:\docs`, `C:\docs`, "", false},
+		{"windows drive and prefix", `C:\docs:/manual:ro`, `C:\docs`, "/manual:ro", true},
+		{"windows drive with slashes", "d:/docs:/manual", "d:/docs", "/manual", true},
+		{"single letter directory", "./a:/manual", "./a", "/manual", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p, rest, ok := cutMountPath(tc.spec)
+			assert.Equal(t, tc.path, p)
+			assert.Equal(t, tc.rest, rest)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
@@ -37,6 +37,9 @@ func (l *logOutput) Write(request *Request) {
 		request.Method,
 		request.Url,
 		contentLengthText)
+	if request.Fault != "" {
+		logLine += " " + getFault(request.Fault)
+	}
 	log.Info(logLine)
 }
 
@@ -46,11 +49,12 @@ func (l *logOutput) Init(location string, addresses []string) {
 }
 
 type tuiOutput struct {
-	model tui.Model
+	model    tui.Model
+	controls []tui.Control
 }
 
-func NewTuiOutput() Output {
-	return &tuiOutput{}
+func NewTuiOutput(controls ...tui.Control) Output {
+	return &tuiOutput{controls: controls}
 }
 
 func (t *tuiOutput) Write(request *Request) {
@@ -61,6 +65,9 @@ func (t *tuiOutput) Write(request *Request) {
 
 	contentPart := tui.SecondaryTextStyle.Render(fmt.Sprintf("%s %s", request.ContentType, contentLengthText))
 	description := fmt.Sprintf("%s %v %s", statusText, request.Time, contentPart)
+	if request.Fault != "" {
+		description = getFault(request.Fault) + " " + description
+	}
 	t.model.Add(title, description)
 }
 
@@ -72,7 +79,7 @@ func (t *tuiOutput) Init(location string, addresses []string) {
 		location,
 		addrs,
 	)
-	t.model = tui.NewModel(servingInfo)
+	t.model = tui.NewModel(servingInfo, t.controls...)
 	go func() {
 		if _, err := tea.NewProgram(t.model).Run(); err != nil {
 			log.Fatal("Error running TUI", "error", err.Error())
@@ -88,3 +95,7 @@ func getContentLength(value uint64) string {
 	}
 	return ""
 }
+
+func getFault(fault string) string {
+	return tui.FaultStyle.Render(fmt.Sprintf("[fault: %s]", fault))
+}
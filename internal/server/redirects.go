@@ -0,0 +1,305 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redirectsFile holds the redirect and rewrite rules of the served
+// directory, in the format of Netlify.
+// See https://docs.netlify.com/routing/redirects/.
+const redirectsFile = "_redirects"
+
+var redirectPlaceholder = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+// redirectTargetKey is the context key of the target of external rewrites.
+type redirectTargetKey struct{}
+
+// RedirectRule sends the requests for From to To. 3xx statuses redirect the
+// client, while the rest rewrite the request to serve To with that status,
+// which can also be an external URL to proxy. Segments of From starting
+// with ':' and a trailing '*' capture parameters, used in To as :name and
+// :splat. Unless Force is set, rules only apply to paths without a file.
+type RedirectRule struct {
+	From    string
+	To      string
+	Status  int
+	Force   bool
+	Query   map[string]string
+	Headers map[string][]string
+	proxy   *httputil.ReverseProxy
+}
+
+// ParseRedirectRules parses specs in the format of the lines of a
+// _redirects file:
+//
+//	/from [query=value|query=:param]... /to [status][!] [Header=value[,value]]...
+func ParseRedirectRules(specs []string) ([]RedirectRule, error) {
+	var rules []RedirectRule
+	for _, spec := range specs {
+		rule, err := parseRedirectRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRedirectRule(spec string) (RedirectRule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "/") {
+		return RedirectRule{}, fmt.Errorf("invalid rule %q, expected \"/from /to [status]\"", spec)
+	}
+	rule := RedirectRule{From: fields[0], Status: http.StatusMovedPermanently}
+	fields = fields[1:]
+	for len(fields) > 0 && !isRedirectTarget(fields[0]) {
+		key, value, ok := strings.Cut(fields[0], "=")
+		if !ok || key == "" {
+			return RedirectRule{}, fmt.Errorf("invalid query condition %q in rule %q", fields[0], spec)
+		}
+		if rule.Query == nil {
+			rule.Query = make(map[string]string)
+		}
+		rule.Query[key] = value
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return RedirectRule{}, fmt.Errorf("missing target in rule %q", spec)
+	}
+	rule.To = fields[0]
+	fields = fields[1:]
+	if !strings.HasPrefix(rule.To, "/") {
+		rule.proxy = newExternalProxy()
+	}
+
+	if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		status, force := strings.CutSuffix(fields[0], "!")
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 200 || code > 599 {
+			return RedirectRule{}, fmt.Errorf("invalid status %q in rule %q", fields[0], spec)
+		}
+		rule.Status, rule.Force = code, force
+		fields = fields[1:]
+	}
+	for _, field := range fields {
+		name, values, ok := strings.Cut(field, "=")
+		if !ok || name == "" || strings.IndexFunc(name, isNotTokenChar) >= 0 {
+			return RedirectRule{}, fmt.Errorf("invalid header condition %q in rule %q", field, spec)
+		}
+		if rule.Headers == nil {
+			rule.Headers = make(map[string][]string)
+		}
+		name = http.CanonicalHeaderKey(name)
+		rule.Headers[name] = append(rule.Headers[name], strings.Split(values, ",")...)
+	}
+	return rule, nil
+}
+
+// newExternalProxy returns the proxy of the external rewrites of a rule, to
+// the target in the context of the request. Credentials are not sent to
+// the third-party host: neither those of servant nor the client cookies.
+func newExternalProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{Director: func(pr *http.Request) {
+		target := pr.Context().Value(redirectTargetKey{}).(*url.URL)
+		pr.URL = target
+		pr.Host = target.Host
+		pr.Header.Del("Authorization")
+		pr.Header.Del("Proxy-Authorization")
+		pr.Header.Del("Cookie")
+	}}
+}
+
+func isRedirectTarget(field string) bool {
+	return strings.HasPrefix(field, "/") || strings.HasPrefix(field, "http://") || strings.HasPrefix(field, "https://")
+}
+
+func (rr *RedirectRule) isRedirect() bool {
+	return rr.Status >= 300 && rr.Status < 400
+}
+
+// match reports whether the rule applies to r, with the parameters captured
+// from its path and query.
+func (rr *RedirectRule) match(r *http.Request) (map[string]string, bool) {
+	params := make(map[string]string)
+	pattern := strings.Split(strings.Trim(rr.From, "/"), "/")
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i, p := range pattern {
+		if p == "*" && i == len(pattern)-1 {
+			if len(segments) < i {
+				return nil, false
+			}
+			params["splat"] = strings.Join(segments[i:], "/")
+			segments = segments[:i]
+			pattern = pattern[:i]
+			break
+		}
+	}
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	for i, p := range pattern {
+		switch {
+		case strings.HasPrefix(p, ":") && segments[i] != "":
+			params[p[1:]] = segments[i]
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+
+	query := r.URL.Query()
+	for key, value := range rr.Query {
+		got := query.Get(key)
+		switch {
+		case got == "":
+			return nil, false
+		case strings.HasPrefix(value, ":"):
+			params[value[1:]] = got
+		case value != got:
+			return nil, false
+		}
+	}
+	for name, values := range rr.Headers {
+		got := r.Header.Get(name)
+		found := false
+		for _, v := range values {
+			found = found || v == got
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// target returns the URL of To for the parameters. The query of the request
+// is kept, unless To has its own.
+func (rr *RedirectRule) target(r *http.Request, params map[string]string) (*url.URL, error) {
+	to := redirectPlaceholder.ReplaceAllStringFunc(rr.To, func(s string) string {
+		if v, ok := params[s[1:]]; ok {
+			return v
+		}
+		return s
+	})
+	target, err := url.Parse(to)
+	if err != nil {
+		return nil, err
+	}
+	if target.RawQuery == "" {
+		target.RawQuery = r.URL.RawQuery
+	}
+	return target, nil
+}
+
+// loadRedirectRules returns the rules of the _redirects file found in root,
+// followed by those of the configuration. Invalid lines are skipped.
+func loadRedirectRules(root http.FileSystem, config Configuration) []RedirectRule {
+	var rules []RedirectRule
+	if f, err := root.Open("/" + redirectsFile); err == nil {
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			rule, err := parseRedirectRule(line)
+			if err != nil {
+				log.Warn("Invalid redirect rule", "file", redirectsFile, "line", n, "error", err)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+		_ = f.Close()
+	}
+	return append(rules, config.Redirects...)
+}
+
+// serveRedirects applies the first rule matching r, and reports whether
+// there was any. Redirects are sent with the prefix of the mount, external
+// rewrites are proxied and the rest are left to rewrite, with the path of
+// the target. exists tells whether there is a file for a path, nil when
+// that can't be known and all the rules apply.
+func serveRedirects(
+	w http.ResponseWriter,
+	r *http.Request,
+	rules []RedirectRule,
+	prefix string,
+	exists func(upath string) bool,
+	rewrite func(w http.ResponseWriter, r *http.Request, upath string),
+) bool {
+	for i := range rules {
+		rule := &rules[i]
+		params, ok := rule.match(r)
+		if !ok || (!rule.Force && exists != nil && exists(r.URL.Path)) {
+			continue
+		}
+		target, err := rule.target(r, params)
+		if err != nil {
+			logf(r, "http: invalid redirect target %q: %v", rule.To, err)
+			continue
+		}
+		switch {
+		case rule.isRedirect():
+			if !target.IsAbs() {
+				target.Path = prefix + target.Path
+			}
+			w.Header().Set("Location", target.String())
+			w.WriteHeader(rule.Status)
+		case target.IsAbs() && rule.proxy != nil:
+			ctx := context.WithValue(r.Context(), redirectTargetKey{}, target)
+			rule.proxy.ServeHTTP(newStatusWriter(w, rule.Status), r.WithContext(ctx))
+		default:
+			rr := r.Clone(r.Context())
+			rr.URL.RawQuery = target.RawQuery
+			rewrite(newStatusWriter(w, rule.Status), rr, path.Clean("/"+target.Path))
+		}
+		return true
+	}
+	return false
+}
+
+// statusWriter replaces the OK status of responses, for rewrites with
+// another status like 404.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func newStatusWriter(w http.ResponseWriter, status int) http.ResponseWriter {
+	if status == http.StatusOK {
+		return w
+	}
+	return &statusWriter{ResponseWriter: w, status: status}
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader && code == http.StatusOK {
+		code = sw.status
+	}
+	sw.wroteHeader = true
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,157 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRedirectRules(t *testing.T) {
+	tt := []struct {
+		name     string
+		spec     string
+		expected RedirectRule
+		wantErr  bool
+	}{
+		{
+			"default status",
+			"/old /new",
+			RedirectRule{From: "/old", To: "/new", Status: http.StatusMovedPermanently},
+			false,
+		},
+		{
+			"forced rewrite",
+			"/* /index.html 200!",
+			RedirectRule{From: "/*", To: "/index.html", Status: http.StatusOK, Force: true},
+			false,
+		},
+		{
+			"query and header conditions",
+			"/store id=:id /products/:id 302 Accept-Language=es,ca",
+			RedirectRule{
+				From:    "/store",
+				To:      "/products/:id",
+				Status:  http.StatusFound,
+				Query:   map[string]string{"id": ":id"},
+				Headers: map[string][]string{"Accept-Language": {"es", "ca"}},
+			},
+			false,
+		},
+		{
+			"external target",
+			"/api/* https://api.example.com/:splat 200",
+			RedirectRule{From: "/api/*", To: "https://api.example.com/:splat", Status: http.StatusOK},
+			false,
+		},
+		{"missing target", "/old", RedirectRule{}, true},
+		{"relative source", "old /new", RedirectRule{}, true},
+		{"invalid status", "/old /new 9000", RedirectRule{}, true},
+		{"invalid condition", "/old /new 301 Accept", RedirectRule{}, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := parseRedirectRule(tc.spec)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			rule.proxy = nil
+			assert.Equal(t, tc.expected, rule)
+		})
+	}
+}
+
+func TestServeRedirects(t *testing.T) {
+	rules, err := ParseRedirectRules([]string{
+		"/blog/:year/:slug /posts/:slug?year=:year 301",
+		"/docs/v1/* /docs/v2/:splat 301",
+		"/docs/* /manual/:splat 302",
+		"/search q=:q /find/:q 307",
+		"/beta/* /next/:splat 200 X-Beta=1",
+		"/gone /404.html 410",
+		"/app/* /app/index.html 200",
+	})
+	assert.NoError(t, err)
+	existing := map[string]bool{"/app/about.html": true}
+
+	tt := []struct {
+		name     string
+		target   string
+		header   http.Header
+		handled  bool
+		status   int
+		location string
+		rewrite  string
+	}{
+		{"path parameters", "/blog/2023/hello", nil, true, 301, "/mount/posts/hello?year=2023", ""},
+		{"splat", "/docs/a/b.html", nil, true, 302, "/mount/manual/a/b.html", ""},
+		{"splat after several segments", "/docs/v1/a.html", nil, true, 301, "/mount/docs/v2/a.html", ""},
+		{"shorter than the splat prefix", "/", nil, false, 0, "", ""},
+		{"splat prefix only", "/docs/v1", nil, true, 301, "/mount/docs/v2/", ""},
+		{"query parameter", "/search?q=go", nil, true, 307, "/mount/find/go?q=go", ""},
+		{"missing query parameter", "/search", nil, false, 0, "", ""},
+		{"header condition", "/beta/x", http.Header{"X-Beta": {"1"}}, true, 200, "", "/next/x"},
+		{"unmet header condition", "/beta/x", nil, false, 0, "", ""},
+		{"rewrite with status", "/gone", nil, true, 410, "", "/404.html"},
+		{"shadowed by a file", "/app/about.html", nil, false, 0, "", ""},
+		{"not shadowed", "/app/contact", nil, true, 200, "", "/app/index.html"},
+		{"no rule", "/other", nil, false, 0, "", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			var rewritten string
+			handled := serveRedirects(w, r, rules, "/mount", func(upath string) bool {
+				return existing[upath]
+			}, func(w http.ResponseWriter, r *http.Request, upath string) {
+				rewritten = upath
+				w.WriteHeader(http.StatusOK)
+			})
+			assert.Equal(t, tc.handled, handled)
+			if !handled {
+				return
+			}
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.location, w.Header().Get("Location"))
+			assert.Equal(t, tc.rewrite, rewritten)
+		})
+	}
+}
+
+func TestExternalRewrite(t *testing.T) {
+	var upstream *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r
+		_, _ = w.Write([]byte("upstream"))
+	}))
+	defer server.Close()
+	rules, err := ParseRedirectRules([]string{"/api/* " + server.URL + "/v1/:splat 200"})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users?page=2", nil)
+	r.SetBasicAuth("user", "secret")
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("Proxy-Authorization", "Basic x")
+	r.Header.Set("X-Custom", "kept")
+	w := httptest.NewRecorder()
+	assert.True(t, serveRedirects(w, r, rules, "", nil, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "upstream", w.Body.String())
+	assert.Equal(t, "/v1/users", upstream.URL.Path)
+	assert.Equal(t, "page=2", upstream.URL.RawQuery)
+	assert.Empty(t, upstream.Header.Get("Authorization"))
+	assert.Empty(t, upstream.Header.Get("Cookie"))
+	assert.Empty(t, upstream.Header.Get("Proxy-Authorization"))
+	assert.Equal(t, "kept", upstream.Header.Get("X-Custom"))
+}
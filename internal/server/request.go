@@ -17,6 +17,7 @@ type Request struct {
 	Body          *io.ReadCloser
 	ContentType   string
 	ContentLength uint64
+	Fault         string
 	// TODO: headers
 }
 
@@ -0,0 +1,270 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sahilm/fuzzy"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	searchPath = internalPrefix + "search"
+
+	queryParam   = "q"
+	pathParam    = "path"
+	contentParam = "content"
+
+	// searchMaxResults caps the results of a search, the tree may be huge.
+	searchMaxResults = 200
+	// searchMaxLine is the length at which matching lines are cut.
+	searchMaxLine = 200
+)
+
+// errSearchDone stops the walk once there are enough results.
+var errSearchDone = errors.New("search done")
+
+const searchTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Search {{.Query}}</title>
+{{.Styles}}
+</head>
+<body>
+<a href="{{.Base}}{{.Path}}">↑ Back to {{.Base}}{{.Path}}</a>
+<form class="archives" action="" method="get">
+	<input id="filter" type="search" name="q" value="{{.Query}}" placeholder="Name, glob or text" autofocus>
+	<input type="hidden" name="path" value="{{.Base}}{{.Path}}">
+	<label><input type="checkbox" name="content" value="1"{{if .Content}} checked{{end}}> Search content</label>
+	<button>Search</button>
+</form>
+{{- if .Results}}
+<table>
+<tr><td></td><td>Name</td><td>{{if .Content}}Line{{else}}Size{{end}}</td></tr>
+{{- range .Results}}
+<tr><td>[{{.Type}}]</td><td><a href="{{.URL}}">{{.Name}}</a></td><td>{{if .Line}}{{.Line}}: <code>{{.Text}}</code>{{else}}{{size .Size}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- if .Truncated}}
+<p>Only the first {{len .Results}} results are shown.</p>
+{{- end}}
+{{- else if .Query}}
+<h4>No results</h4>
+{{- end}}
+</body>
+</html>
+`
+
+var searchPage = template.Must(template.New("search").Funcs(listingFuncs).Parse(searchTemplate))
+
+// searchResult is a file or directory found by a search, with the first
+// matching line when searching content.
+type searchResult struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	IsDir    bool      `json:"isDir"`
+	Type     string    `json:"type"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Line     int       `json:"line,omitempty"`
+	Text     string    `json:"text,omitempty"`
+}
+
+// searchData is the model of a search, rendered as HTML or JSON.
+type searchData struct {
+	Query     string         `json:"query"`
+	Path      string         `json:"path"`
+	Base      string         `json:"-"`
+	Content   bool           `json:"content"`
+	Results   []searchResult `json:"results"`
+	Truncated bool           `json:"truncated"`
+	Styles    template.HTML  `json:"-"`
+}
+
+// serveSearch looks for files below the directory given in the path param
+// (the root by default). Names are matched as globs if the query has any
+// wildcard, and fuzzily otherwise. With the content param, the text of the
+// files up to the configured size is searched instead.
+func (fh *fileHandler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	if fh.config.NoListing {
+		fh.writeError(w, r, "Directory listing is disabled", http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	data := &searchData{
+		Query:   strings.TrimSpace(query.Get(queryParam)),
+		Path:    path.Clean("/" + query.Get(pathParam)),
+		Base:    fh.config.Prefix,
+		Content: query.Get(contentParam) != "",
+		Results: []searchResult{},
+	}
+	// The path of the listings includes the prefix of the mount
+	if data.Base != "" {
+		data.Path = path.Clean("/" + strings.TrimPrefix(data.Path, data.Base))
+	}
+	if data.Path != "/" {
+		data.Path += "/"
+	}
+
+	if data.Query != "" {
+		var err error
+		if data.Content {
+			err = fh.searchContent(r, data)
+		} else {
+			err = fh.searchNames(r, data)
+		}
+		if errors.Is(err, path.ErrBadPattern) {
+			fh.writeError(w, r, "malformed glob pattern", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logf(r, "http: error searching: %v", err)
+			fh.serveError(w, r, err)
+			return
+		}
+	}
+
+	addVary(w.Header(), "Accept")
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	data.Styles = fileServerCss
+	var buf bytes.Buffer
+	if err := searchPage.Execute(&buf, data); err != nil {
+		logf(r, "http: error rendering search: %v", err)
+		fh.writeError(w, r, "Error rendering search", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// searchNames matches the query against the names of the files, or against
+// their paths if it has a '/'.
+func (fh *fileHandler) searchNames(r *http.Request, data *searchData) error {
+	glob := strings.ContainsAny(data.Query, "*?[")
+	if _, err := path.Match(data.Query, ""); glob && err != nil {
+		return err
+	}
+
+	var names []string
+	var infos []fs.FileInfo
+	err := fh.walkSearch(r, data.Path, func(name string, info fs.FileInfo) error {
+		rel := strings.TrimPrefix(name, data.Path)
+		if !glob {
+			names = append(names, rel)
+			infos = append(infos, info)
+			return nil
+		}
+		subject := info.Name()
+		if strings.Contains(data.Query, "/") {
+			subject = rel
+		}
+		if ok, _ := path.Match(data.Query, subject); ok {
+			return data.add(name, info, 0, "")
+		}
+		return nil
+	})
+	if err != nil || glob {
+		return err
+	}
+
+	for _, match := range fuzzy.Find(data.Query, names) {
+		if data.add(path.Join(data.Path, match.Str), infos[match.Index], 0, "") != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// searchContent looks for the query in the lines of the text files,
+// ignoring case. Only the first matching line of each file is reported.
+func (fh *fileHandler) searchContent(r *http.Request, data *searchData) error {
+	needle := []byte(strings.ToLower(data.Query))
+	return fh.walkSearch(r, data.Path, func(name string, info fs.FileInfo) error {
+		if !info.Mode().IsRegular() || info.Size() > fh.config.SearchMaxSize {
+			return nil
+		}
+		f, err := fh.root.Open(name)
+		if err != nil {
+			return nil
+		}
+		content, err := io.ReadAll(io.LimitReader(f, fh.config.SearchMaxSize))
+		_ = f.Close()
+		if err != nil || bytes.IndexByte(content, 0) >= 0 {
+			// Unreadable or binary
+			return nil
+		}
+		for i, line := range bytes.Split(content, []byte("\n")) {
+			if bytes.Contains(bytes.ToLower(line), needle) {
+				text := strings.TrimSpace(string(line))
+				if runes := []rune(text); len(runes) > searchMaxLine {
+					text = string(runes[:searchMaxLine]) + "…"
+				}
+				return data.add(name, info, i+1, text)
+			}
+		}
+		return nil
+	})
+}
+
+// walkSearch walks the tree below dir, except dir itself, until fn returns
+// errSearchDone or the request is canceled.
+func (fh *fileHandler) walkSearch(r *http.Request, dir string, fn walkFunc) error {
+	dir = path.Clean(dir)
+	err := walkFS(r.Context(), fh.root, dir, func(name string, info fs.FileInfo) error {
+		if name == dir {
+			return nil
+		}
+		return fn(name, info)
+	})
+	if errors.Is(err, errSearchDone) {
+		return nil
+	}
+	return err
+}
+
+// add appends a result, and returns errSearchDone when there are enough.
+func (d *searchData) add(name string, info fs.FileInfo, line int, text string) error {
+	if len(d.Results) == searchMaxResults {
+		d.Truncated = true
+		return errSearchDone
+	}
+	result := searchResult{
+		Name:     strings.TrimPrefix(name, d.Path),
+		IsDir:    info.IsDir(),
+		Type:     "F",
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+		Line:     line,
+		Text:     text,
+	}
+	if result.IsDir {
+		result.Type = "D"
+		result.Size = 0
+		name += "/"
+		result.Name += "/"
+	}
+	_url := url.URL{Path: d.Base + name}
+	if line > 0 {
+		// Straight to the line in the highlighted source
+		_url.RawQuery = url.Values{viewParam: {viewSource}}.Encode()
+		_url.Fragment = fmt.Sprintf("L%d", line)
+	}
+	result.URL = _url.String()
+	d.Results = append(d.Results, result)
+	return nil
+}
@@ -0,0 +1,96 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearch(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":             {Data: []byte("<h1>Home</h1>")},
+		"docs/guide.md":          {Data: []byte("# Guide\nInstall with go install")},
+		"docs/api/reference.md":  {Data: []byte("# Reference")},
+		"assets/app.js":          {Data: []byte("console.log('install')")},
+		"assets/logo.png":        {Data: []byte{0x89, 'P', 'N', 'G', 0, 'i', 'n', 's', 't', 'a', 'l', 'l'}},
+		"assets/fonts/guide.ttf": {Data: []byte{0}},
+	}
+	tt := []struct {
+		name     string
+		query    string
+		path     string
+		content  bool
+		expected []string
+	}{
+		{
+			"fuzzy",
+			"guide",
+			"/",
+			false,
+			[]string{"docs/guide.md", "assets/fonts/guide.ttf"},
+		},
+		{
+			"fuzzy in directory",
+			"guide",
+			"/docs",
+			false,
+			[]string{"guide.md"},
+		},
+		{
+			"glob on names",
+			"*.md",
+			"/",
+			false,
+			[]string{"docs/api/reference.md", "docs/guide.md"},
+		},
+		{
+			"glob on paths",
+			"docs/*",
+			"/",
+			false,
+			[]string{"docs/api/", "docs/guide.md"},
+		},
+		{
+			"content ignoring case and binaries",
+			"INSTALL",
+			"/",
+			true,
+			[]string{"assets/app.js", "docs/guide.md"},
+		},
+		{
+			"no results",
+			"missing",
+			"/",
+			false,
+			[]string{},
+		},
+	}
+
+	fh := &fileHandler{root: http.FS(files), config: Configuration{SearchMaxSize: 1024}}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, searchPath, nil)
+			data := &searchData{Query: tc.query, Path: tc.path, Content: tc.content, Results: []searchResult{}}
+			if data.Path != "/" {
+				data.Path += "/"
+			}
+			var err error
+			if tc.content {
+				err = fh.searchContent(r, data)
+			} else {
+				err = fh.searchNames(r, data)
+			}
+			assert.NoError(t, err)
+			names := []string{}
+			for _, result := range data.Results {
+				names = append(names, result.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -22,20 +23,52 @@ type Type string
 const (
 	TypeLocal  Type = "local"
 	TypeRemote Type = "remote"
+	TypeMock   Type = "mock"
 )
 
 type Configuration struct {
-	Type       Type
-	Path       string
-	Host       string
-	Subdomain  string
-	TLS        TLSRequest
-	Port       int
-	Expose     bool
-	CORS       bool
-	Launch     bool
-	Auth       string
-	DisableTUI bool
+	Type            Type
+	Path            string
+	Prefix          string
+	Mounts          []Mount
+	Host            string
+	Subdomain       string
+	TLS             TLSRequest
+	Port            int
+	Expose          bool
+	CORS            bool
+	Launch          bool
+	Auth            string
+	DisableTUI      bool
+	WebDAV          bool
+	ReadOnly        bool
+	NoListing       bool
+	Upload          bool
+	UploadMaxSize   int64
+	GitRef          string
+	Ignore          []string
+	ArchiveMaxSize  int64
+	SearchMaxSize   int64
+	Compress        bool
+	CompressMinSize int64
+	SPA             bool
+	SPAFallback     string
+	SPAExclude      []string
+	LiveReload      bool
+	ShowHidden      bool
+	ListingTemplate string
+	Theme           string
+	ETag            string
+	Symlinks        string
+	Throttle        string
+	ThrottleDown    int64
+	ThrottleUp      int64
+	ThrottleGlobal  bool
+	Latency         time.Duration
+	Faults          []FaultRule
+	Headers         []HeaderRule
+	Redirects       []RedirectRule
+	VHosts          []VHost
 }
 
 func (r *Configuration) WantsAutoTLS() bool {
@@ -66,37 +99,62 @@ type Servant struct {
 }
 
 func New(config Configuration) *Servant {
+	throttle := newThrottle(config)
+	faults := newFaultInjector(config)
 	var output Output
 	if config.DisableTUI {
 		log.Debug("Using Log output")
 		output = NewLogOutput()
 	} else {
 		log.Debug("Using TUI output")
-		output = NewTuiOutput()
+		output = NewTuiOutput(throttle.control(), faults.control())
 	}
 
 	var server Server
 	var handler RequestHandler
 	var httpHandler Handler
 	var location string
-	if config.Type == TypeLocal {
+	if config.Type == TypeLocal || config.Type == TypeMock {
 		location = config.Path
-		httpHandler = FileServer(http.Dir(config.Path))
+		if len(config.Mounts) > 0 {
+			var paths []string
+			for _, m := range config.Mounts {
+				paths = append(paths, fmt.Sprintf("%s (%s)", m.Path, m.Prefix))
+			}
+			location = strings.Join(paths, ", ")
+		}
+		for _, vh := range config.VHosts {
+			target := vh.Upstream
+			if target == "" {
+				var paths []string
+				for _, m := range vh.Mounts {
+					paths = append(paths, m.Path)
+				}
+				target = strings.Join(paths, " ")
+			}
+			location += fmt.Sprintf(", %s (%s)", target, vh.Host)
+		}
+		if config.Type == TypeMock {
+			httpHandler = MockServer(config)
+		} else {
+			httpHandler = VHostServer(config, MountServer(config))
+		}
 		server = newLocal(config)
-		handler = newLocalHandler(config, output)
+		handler = newLocalHandler(config, output, faults)
 		if config.Expose {
 			server = newRemote(config)
 		}
 	} else {
 		location = fmt.Sprintf("port %d", config.Port)
 		server = newRemote(config)
-		handler = newProxyHandler(config, output)
+		handler = newProxyHandler(config, output, faults)
 	}
 	mux, listener, addresses, err := server.Init(handler, httpHandler)
 	if err != nil {
 		log.Debug("net.Listen error", "error", fmt.Sprintf("%#v", err))
 		log.Fatal(err.Error())
 	}
+	listener = throttle.listen(listener)
 
 	output.Init(location, addresses)
 
@@ -110,10 +168,15 @@ func New(config Configuration) *Servant {
 }
 
 func (s *Servant) Start() {
+	// Long-lived requests, like live reload streams, must end when
+	// shutting down, otherwise Shutdown waits for them until it times out
+	ctx, cancel := context.WithCancel(context.Background())
 	server := &http.Server{
-		Addr:    s.listener.Addr().String(),
-		Handler: s.mux,
+		Addr:        s.listener.Addr().String(),
+		Handler:     s.mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
+	server.RegisterOnShutdown(cancel)
 	go s.start(server)
 
 	stopCh, closeCh := createChannel()
@@ -0,0 +1,191 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+)
+
+const (
+	viewParam  = "view"
+	viewSource = "source"
+
+	// sourceMaxSize is the size above which files are never highlighted,
+	// as the page would take too long to render in the browser.
+	sourceMaxSize = 2 << 20
+)
+
+const sourceCss = `
+	<style>
+		.source {
+			margin-top: 20px;
+			font-size: 13px;
+		}
+
+		.source pre {
+			padding: 12px 0;
+			border-radius: 6px;
+			overflow-x: auto;
+		}
+
+		.source pre * {
+			color: inherit;
+		}
+
+		.source .ln a {
+			color: darkgray;
+			text-decoration: none;
+		}
+	</style>
+	`
+
+// sourceLines highlights the lines of the fragment (#L10 or #L10-L20), and
+// extends the selection from the last clicked line with shift-click.
+const sourceLines = `
+	<script>
+		(function () {
+			function highlight() {
+				document.querySelectorAll(".source .line.hl").forEach(function (line) {
+					line.classList.remove("hl");
+				});
+				var match = /^#L(\d+)(?:-L(\d+))?$/.exec(location.hash);
+				if (!match) {
+					return;
+				}
+				var from = parseInt(match[1], 10), to = parseInt(match[2] || match[1], 10);
+				for (var i = Math.min(from, to); i <= Math.max(from, to); i++) {
+					var number = document.getElementById("L" + i);
+					if (number) {
+						number.parentNode.classList.add("hl");
+					}
+				}
+				var first = document.getElementById("L" + Math.min(from, to));
+				if (first) {
+					first.scrollIntoView({block: "center"});
+				}
+			}
+			document.addEventListener("click", function (e) {
+				var link = e.target.closest(".source .ln a");
+				var match = /^#L(\d+)/.exec(location.hash);
+				if (link && e.shiftKey && match) {
+					e.preventDefault();
+					location.hash = "#L" + match[1] + "-" + link.getAttribute("href").substring(1);
+				}
+			});
+			window.addEventListener("hashchange", highlight);
+			document.addEventListener("DOMContentLoaded", highlight);
+		})();
+	</script>
+	`
+
+var (
+	sourceStyle     = styles.Get("github")
+	sourceFormatter = html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.WithLinkableLineNumbers(true, "L"),
+	)
+)
+
+// browserTypes are rendered by browsers on their own, so they are only
+// highlighted when asked for with ?view=source.
+var browserTypes = []string{"text/html", "text/plain", "image/svg+xml", "application/pdf"}
+
+// wantsSource reports whether the file name must be highlighted: always with
+// ?view=source, and for browsers navigating to source files that would be
+// downloaded or shown unstyled otherwise. ?raw=1 gets the original file.
+func wantsSource(r *http.Request, name string) bool {
+	if r.URL.Query().Get(viewParam) == viewSource {
+		return true
+	}
+	return detectsSource(r, name) && wantsHTML(r)
+}
+
+// detectsSource reports whether name is a source file whose response
+// depends on the Accept header, as no view was chosen with ?raw or ?view.
+func detectsSource(r *http.Request, name string) bool {
+	query := r.URL.Query()
+	if query.Get(viewParam) != "" || query.Get(rawParam) != "" || lexers.Match(name) == nil {
+		return false
+	}
+	ctype, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(name)))
+	for _, t := range browserTypes {
+		if ctype == t {
+			return false
+		}
+	}
+	return true
+}
+
+// serveSource renders the text file f as an HTML page with syntax
+// highlighting and reports whether it did. Binary and huge files are left
+// to serveContent.
+func (fh *fileHandler) serveSource(w http.ResponseWriter, r *http.Request, d fs.FileInfo, f io.ReadSeeker) bool {
+	if detectsSource(r, d.Name()) {
+		addVary(w.Header(), "Accept")
+	}
+	if !wantsSource(r, d.Name()) || d.Size() > sourceMaxSize {
+		return false
+	}
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil || bytes.IndexByte(head[:n], 0) >= 0 {
+		return false
+	}
+	if checkIfModifiedSince(r, d.ModTime()) == condFalse {
+		writeNotModified(w)
+		return true
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		logf(r, "http: error reading file: %v", err)
+		fh.serveError(w, r, err)
+		return true
+	}
+	lexer := lexers.Match(d.Name())
+	if lexer == nil {
+		lexer = lexers.Analyse(string(content))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(content))
+	if err != nil {
+		logf(r, "http: error highlighting file: %v", err)
+		fh.writeError(w, r, "Error highlighting file", http.StatusInternalServerError)
+		return true
+	}
+	var body bytes.Buffer
+	if err = sourceFormatter.Format(&body, sourceStyle, iterator); err != nil {
+		logf(r, "http: error highlighting file: %v", err)
+		fh.writeError(w, r, "Error highlighting file", http.StatusInternalServerError)
+		return true
+	}
+
+	setLastModified(w, d.ModTime())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wrapFprintf(w, "<title>%s</title>\n", htmlReplacer.Replace(d.Name()))
+	wrapFprintf(w, "<a href=\"?%s=1\">View raw</a>\n", rawParam)
+	wrapFprintf(w, "<div class=\"source\">\n%s</div>\n", body.Bytes())
+	_, _ = io.WriteString(w, fileServerCss)
+	_, _ = io.WriteString(w, sourceCss)
+	_, _ = io.WriteString(w, "<style>\n")
+	_ = sourceFormatter.WriteCSS(w, sourceStyle)
+	_, _ = io.WriteString(w, "</style>\n")
+	_, _ = io.WriteString(w, sourceLines)
+	if fh.live != nil {
+		_, _ = io.WriteString(w, fh.live.script)
+	}
+	return true
+}
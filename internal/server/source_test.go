@@ -0,0 +1,109 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWantsSource(t *testing.T) {
+	const browser = "text/html,application/xhtml+xml,*/*;q=0.8"
+	tt := []struct {
+		name     string
+		url      string
+		accept   string
+		expected bool
+	}{
+		{
+			"source file from browser",
+			"/main.go",
+			browser,
+			true,
+		},
+		{
+			"source file from command line",
+			"/main.go",
+			"*/*",
+			false,
+		},
+		{
+			"json from browser",
+			"/package.json",
+			browser,
+			true,
+		},
+		{
+			"raw source file",
+			"/config.yaml?raw=1",
+			browser,
+			false,
+		},
+		{
+			"html is rendered by browsers",
+			"/index.html",
+			browser,
+			false,
+		},
+		{
+			"plain text is rendered by browsers",
+			"/notes.txt",
+			browser,
+			false,
+		},
+		{
+			"unknown type",
+			"/image.png",
+			browser,
+			false,
+		},
+		{
+			"view source from command line",
+			"/index.html?view=source",
+			"*/*",
+			true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			r.Header.Set("Accept", tc.accept)
+			res := wantsSource(r, r.URL.Path)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestSourceVary(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), []byte("\x89PNG"), 0o644))
+	handler := FileServer(http.Dir(dir), Configuration{Path: dir})
+
+	tt := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"source file", "/main.go", "Accept"},
+		{"raw source file", "/main.go?raw=1", ""},
+		{"viewed source file", "/main.go?view=source", ""},
+		{"not a source file", "/image.png", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.expected, w.Header().Get("Vary"))
+		})
+	}
+}
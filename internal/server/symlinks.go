@@ -0,0 +1,202 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SymlinksFollow = "follow"
+	SymlinksRoot   = "root"
+	SymlinksDeny   = "deny"
+)
+
+// symlinkPolicy decides which symbolic links of a served directory can be
+// followed: all of them, those whose target is inside the directory, or
+// none. Files behind a link that can't be followed don't exist.
+type symlinkPolicy struct {
+	root string
+	mode string
+}
+
+// CheckSymlinkPolicy reports an error if mode is not a symlink policy.
+// File servers without one use SymlinksRoot.
+func CheckSymlinkPolicy(mode string) error {
+	switch mode {
+	case SymlinksFollow, SymlinksRoot, SymlinksDeny:
+		return nil
+	default:
+		return fmt.Errorf("unknown symlink policy %q, use follow, root or deny", mode)
+	}
+}
+
+// newSymlinkPolicy returns the policy mode for dir. Any mode other than
+// follow and deny, like the empty one, confines links to dir.
+func newSymlinkPolicy(dir, mode string) symlinkPolicy {
+	if mode != SymlinksFollow && mode != SymlinksDeny {
+		mode = SymlinksRoot
+	}
+	root, err := filepath.Abs(dir)
+	if err == nil {
+		// The served directory itself may be a link
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+	}
+	return symlinkPolicy{root: root, mode: mode}
+}
+
+// allows reports whether name, '/'-separated and relative to the root, can
+// be reached through the links of its path. Missing files are allowed, so
+// they can be created.
+func (sp symlinkPolicy) allows(name string) bool {
+	rel := filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))
+	switch sp.mode {
+	case SymlinksDeny:
+		current := sp.root
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			if part == "" {
+				continue
+			}
+			current = filepath.Join(current, part)
+			info, err := os.Lstat(current)
+			if err != nil {
+				return true
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return false
+			}
+		}
+		return true
+	case SymlinksRoot:
+		resolved, err := resolvePath(filepath.Join(sp.root, rel))
+		if err != nil {
+			return false
+		}
+		inside, err := filepath.Rel(sp.root, resolved)
+		return err == nil && inside != ".." && !strings.HasPrefix(inside, ".."+string(filepath.Separator))
+	default:
+		return true
+	}
+}
+
+// resolvePath resolves the links of the existing part of name. Dangling
+// links are an error, as creating name would create their target.
+func resolvePath(name string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return resolved, err
+	}
+	if info, err := os.Lstat(name); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return "", fs.ErrNotExist
+	}
+	parent := filepath.Dir(name)
+	if parent == name {
+		return name, nil
+	}
+	dir, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(name)), nil
+}
+
+// symlinkFileSystem applies a symlinkPolicy to a directory. Listings leave
+// out the links that can't be followed, and describe the rest with the type
+// of their target plus fs.ModeSymlink.
+type symlinkFileSystem struct {
+	http.FileSystem
+	policy symlinkPolicy
+}
+
+func (sfs symlinkFileSystem) Open(name string) (http.File, error) {
+	if !sfs.policy.allows(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := sfs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return symlinkFile{File: f, name: name, policy: sfs.policy}, nil
+}
+
+type symlinkFile struct {
+	http.File
+	name   string
+	policy symlinkPolicy
+}
+
+// target returns the description of the link info, or false if it can't be
+// followed or is dangling.
+func (f symlinkFile) target(info fs.FileInfo) (fs.FileInfo, bool) {
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return info, true
+	}
+	name := path.Join(f.name, info.Name())
+	if !f.policy.allows(name) {
+		return nil, false
+	}
+	target, err := os.Stat(filepath.Join(f.policy.root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, false
+	}
+	return symlinkInfo{FileInfo: target, name: info.Name()}, true
+}
+
+func (f symlinkFile) Readdir(count int) ([]fs.FileInfo, error) {
+	list, err := f.File.Readdir(count)
+	visible := list[:0]
+	for _, info := range list {
+		if info, ok := f.target(info); ok {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
+
+func (f symlinkFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	var list []fs.DirEntry
+	var err error
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		list, err = d.ReadDir(count)
+	} else {
+		var infos []fs.FileInfo
+		infos, err = f.File.Readdir(count)
+		for _, info := range infos {
+			list = append(list, fs.FileInfoToDirEntry(info))
+		}
+	}
+	visible := list[:0]
+	for _, entry := range list {
+		if entry.Type()&fs.ModeSymlink == 0 {
+			visible = append(visible, entry)
+			continue
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			continue
+		}
+		if info, ok := f.target(info); ok {
+			visible = append(visible, fs.FileInfoToDirEntry(info))
+		}
+	}
+	return visible, err
+}
+
+// symlinkInfo describes a link with the information of its target.
+type symlinkInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (si symlinkInfo) Name() string      { return si.name }
+func (si symlinkInfo) Mode() fs.FileMode { return si.FileInfo.Mode() | fs.ModeSymlink }
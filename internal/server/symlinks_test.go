@@ -0,0 +1,52 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSymlinkPolicy(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "outside"), 0o755))
+	links := map[string]string{
+		"out":      filepath.Join("..", "outside"),
+		"insub":    "sub",
+		"dangling": filepath.Join("..", "outside", "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skip("symbolic links not supported:", err)
+		}
+	}
+
+	tt := []struct {
+		name     string
+		mode     string
+		file     string
+		expected bool
+	}{
+		{"follow outside", SymlinksFollow, "/out/secret", true},
+		{"root outside", SymlinksRoot, "/out/secret", false},
+		{"root inside", SymlinksRoot, "/insub/file", true},
+		{"root dangling", SymlinksRoot, "/dangling", false},
+		{"root missing file", SymlinksRoot, "/sub/new.txt", true},
+		{"path cleaned to the root", SymlinksRoot, "/../outside", true},
+		{"deny link", SymlinksDeny, "/insub/file", false},
+		{"deny regular", SymlinksDeny, "/sub/file", true},
+		{"empty is root", "", "/out/secret", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := newSymlinkPolicy(root, tc.mode)
+			assert.Equal(t, tc.expected, policy.allows(tc.file))
+		})
+	}
+}
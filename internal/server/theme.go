@@ -0,0 +1,111 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// themeListing is the name of the listing template inside a theme directory.
+const themeListing = "listing.html"
+
+// errorData is the model of the custom error pages.
+type errorData struct {
+	Status     int
+	StatusText string
+	Message    string
+	Path       string
+}
+
+// loadListingTemplate returns the template used for directory listings: the
+// one given in the configuration, the one of the theme or the default one.
+func loadListingTemplate(config Configuration) *template.Template {
+	file := config.ListingTemplate
+	if file == "" && config.Theme != "" {
+		if _, err := os.Stat(filepath.Join(config.Theme, themeListing)); err == nil {
+			file = filepath.Join(config.Theme, themeListing)
+		}
+	}
+	if file == "" {
+		return defaultDirList
+	}
+	tmpl, err := template.New(filepath.Base(file)).Funcs(listingFuncs).ParseFiles(file)
+	if err != nil {
+		log.Fatal("Error loading listing template", "file", file, "error", err)
+	}
+	log.Debug("Using listing template", "file", file)
+	return tmpl
+}
+
+// errorPage looks for the page of the given status code (404.html, ...) in
+// the served root first, and then in the theme directory. Pages are parsed
+// on each error, so they can be edited while the server is running.
+func (fh *fileHandler) errorPage(code int) *template.Template {
+	name := fmt.Sprintf("/%d.html", code)
+	sources := []http.FileSystem{fh.root}
+	if fh.config.Theme != "" {
+		sources = append(sources, http.Dir(fh.config.Theme))
+	}
+	for _, source := range sources {
+		f, err := source.Open(name)
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		tmpl, err := template.New(name).Parse(string(content))
+		if err != nil {
+			log.Warn("Error parsing error page", "page", name, "error", err)
+			continue
+		}
+		return tmpl
+	}
+	return nil
+}
+
+// serveError replies with the error page of err, see toHTTPError.
+func (fh *fileHandler) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	msg, code := toHTTPError(err)
+	fh.writeError(w, r, msg, code)
+}
+
+// writeError replies with the custom page of code, if there is one,
+// or with msg as plain text like http.Error. The headers describing the
+// content that was going to be served are dropped, as http.ServeContent
+// does for errors.
+func (fh *fileHandler) writeError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	h := w.Header()
+	h.Del("Content-Encoding")
+	h.Del("Etag")
+	h.Del("Last-Modified")
+	if page := fh.errorPage(code); page != nil {
+		var buf bytes.Buffer
+		err := page.Execute(&buf, errorData{
+			Status:     code,
+			StatusText: http.StatusText(code),
+			Message:    msg,
+			Path:       fh.config.Prefix + r.URL.Path,
+		})
+		if err == nil {
+			h.Del("Content-Length")
+			h.Set("Content-Type", "text/html; charset=utf-8")
+			h.Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(code)
+			_, _ = buf.WriteTo(w)
+			return
+		}
+		log.Warn("Error rendering error page", "status", code, "error", err)
+	}
+	http.Error(w, msg, code)
+}
@@ -0,0 +1,96 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	dir, theme := t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"file.txt": "servant",
+		"404.html": "root {{.Status}} {{.StatusText}} {{.Path}}",
+		"400.html": "broken {{.Nope",
+	})
+	writeFiles(t, theme, map[string]string{
+		"404.html": "theme {{.Status}}",
+		"400.html": "theme {{.Status}} {{.Message}}",
+		"416.html": "theme {{.Status}} {{.StatusText}}",
+	})
+
+	tt := []struct {
+		name     string
+		theme    string
+		target   string
+		rangeHdr string
+		status   int
+		expected string
+	}{
+		{"served directory first", theme, "/missing.txt", "", http.StatusNotFound, "root 404 Not Found /missing.txt"},
+		{"served directory without theme", "", "/missing.txt", "", http.StatusNotFound, "root 404 Not Found /missing.txt"},
+		{"theme when the page is invalid", theme, "/?archive=rar", "", http.StatusBadRequest, "theme 400 unsupported archive format"},
+		{"theme for content errors", theme, "/file.txt", "bytes=100-200", http.StatusRequestedRangeNotSatisfiable, "theme 416 Requested Range Not Satisfiable"},
+		{"plain text without pages", "", "/file.txt", "bytes=100-200", http.StatusRequestedRangeNotSatisfiable, "invalid range: failed to overlap\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := FileServer(http.Dir(dir), Configuration{Path: dir, Theme: tc.theme})
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.rangeHdr != "" {
+				r.Header.Set("Range", tc.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.expected, w.Body.String())
+		})
+	}
+}
+
+func TestListingTemplate(t *testing.T) {
+	dir, theme, other := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "a", "b/c.txt": "c"})
+	writeFiles(t, theme, map[string]string{themeListing: "theme:{{range .Entries}} {{.Name}}{{end}}"})
+	listing := filepath.Join(other, "custom.html")
+	writeFiles(t, other, map[string]string{"custom.html": "custom {{.Path}} {{.Sort}}:{{range .Entries}} {{.Name}}{{if not .IsDir}} {{size .Size}}{{end}}{{end}}"})
+
+	tt := []struct {
+		name     string
+		config   Configuration
+		expected string
+	}{
+		{"theme", Configuration{Theme: theme}, "theme: SHA256SUMS a.txt b/"},
+		{"listing template over the theme", Configuration{Theme: theme, ListingTemplate: listing}, "custom / name: SHA256SUMS 72 B a.txt 1 B b/"},
+		{"theme without listing", Configuration{Theme: other}, "<!DOCTYPE html>"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			config.Path = dir
+			handler := FileServer(http.Dir(dir), config)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"fmt"
+	"github.com/charmbracelet/log"
+	"github.com/planta7/servant/internal/tui"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// throttleChunk is the time worth of data written at once, so several
+	// connections sharing the bandwidth progress evenly.
+	throttleChunk    = 50 * time.Millisecond
+	throttleMinChunk = 512
+)
+
+// throttleProfile describes the conditions of a network. Rates are in
+// kbit/s, and zero means unlimited.
+type throttleProfile struct {
+	name    string
+	down    int64
+	up      int64
+	latency time.Duration
+}
+
+func (p *throttleProfile) String() string {
+	rate := func(kbps int64) string {
+		if kbps <= 0 {
+			return "unlimited"
+		}
+		return fmt.Sprintf("%d kbit/s", kbps)
+	}
+	return fmt.Sprintf("%s (down %s, up %s, latency %v)", p.name, rate(p.down), rate(p.up), p.latency)
+}
+
+// throttlePresets are the network profiles of browser devtools.
+var throttlePresets = []throttleProfile{
+	{name: "slow-3g", down: 400, up: 400, latency: 2000 * time.Millisecond},
+	{name: "3g", down: 1600, up: 750, latency: 562 * time.Millisecond},
+	{name: "4g", down: 9000, up: 9000, latency: 170 * time.Millisecond},
+	{name: "dsl", down: 1500, up: 384, latency: 50 * time.Millisecond},
+	{name: "wifi", down: 30000, up: 15000, latency: 2 * time.Millisecond},
+}
+
+// throttle simulates slow networks on the connections of a listener. The
+// profile can be changed at any time, and applies to the connections that
+// are already open.
+type throttle struct {
+	profiles []throttleProfile
+	index    atomic.Int32
+	global   bool
+	down, up bandwidth
+}
+
+// newThrottle returns the throttle of config: a preset, optionally with
+// its rates or latency replaced, or only the given rates and latency.
+func newThrottle(config Configuration) *throttle {
+	t := &throttle{profiles: throttlePresets, global: config.ThrottleGlobal}
+	t.index.Store(-1)
+	if config.Throttle == "" && config.ThrottleDown == 0 && config.ThrottleUp == 0 && config.Latency == 0 {
+		return t
+	}
+	profile := throttleProfile{name: "custom"}
+	if config.Throttle != "" {
+		found := false
+		for _, p := range throttlePresets {
+			if p.name == config.Throttle {
+				profile, found = p, true
+			}
+		}
+		if !found {
+			log.Fatal("Unknown network profile, use slow-3g, 3g, 4g, dsl or wifi", "throttle", config.Throttle)
+		}
+	}
+	if config.ThrottleDown != 0 || config.ThrottleUp != 0 || config.Latency != 0 {
+		if config.ThrottleDown != 0 {
+			profile.down = config.ThrottleDown
+		}
+		if config.ThrottleUp != 0 {
+			profile.up = config.ThrottleUp
+		}
+		if config.Latency != 0 {
+			profile.latency = config.Latency
+		}
+		if profile.name != "custom" {
+			profile.name += "*"
+		}
+		t.profiles = append([]throttleProfile{profile}, throttlePresets...)
+		t.index.Store(0)
+	} else {
+		for i, p := range t.profiles {
+			if p.name == profile.name {
+				t.index.Store(int32(i))
+			}
+		}
+	}
+	log.Info("Throttling connections", "profile", profile.String(), "global", t.global)
+	return t
+}
+
+// profile returns the current profile, or nil if throttling is off.
+func (t *throttle) profile() *throttleProfile {
+	if i := t.index.Load(); i >= 0 {
+		return &t.profiles[i]
+	}
+	return nil
+}
+
+// next switches to the next profile, and after the last one, turns
+// throttling off. It returns the description of the new state.
+func (t *throttle) next() string {
+	i := t.index.Add(1)
+	if int(i) >= len(t.profiles) {
+		t.index.Store(-1)
+		return "Throttling off"
+	}
+	return "Throttling " + t.profiles[i].String()
+}
+
+func (t *throttle) control() tui.Control {
+	return tui.Control{Key: "t", Help: "cycle network profile", Change: t.next}
+}
+
+func (t *throttle) listen(listener net.Listener) net.Listener {
+	return throttledListener{Listener: listener, throttle: t}
+}
+
+type throttledListener struct {
+	net.Listener
+	throttle *throttle
+}
+
+func (tl throttledListener) Accept() (net.Conn, error) {
+	conn, err := tl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc := &throttledConn{Conn: conn, throttle: tl.throttle, down: &tl.throttle.down, up: &tl.throttle.up}
+	if !tl.throttle.global {
+		tc.down, tc.up = &bandwidth{}, &bandwidth{}
+	}
+	tc.idle.Store(true)
+	return tc, nil
+}
+
+// throttledConn limits the rates of a connection, and delays the first
+// read after each response by the latency, as a new request takes a round
+// trip to arrive.
+type throttledConn struct {
+	net.Conn
+	throttle *throttle
+	down, up *bandwidth
+	idle     atomic.Bool
+}
+
+func (c *throttledConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	p := c.throttle.profile()
+	if p == nil || n == 0 {
+		return n, err
+	}
+	if c.idle.Swap(false) {
+		time.Sleep(p.latency)
+	}
+	c.up.wait(n, p.up)
+	return n, err
+}
+
+func (c *throttledConn) Write(b []byte) (int, error) {
+	written := 0
+	defer c.idle.Store(true)
+	for len(b) > 0 {
+		p := c.throttle.profile()
+		if p == nil || p.down <= 0 {
+			n, err := c.Conn.Write(b)
+			return written + n, err
+		}
+		chunk := int(bytesPerSecond(p.down) * int64(throttleChunk) / int64(time.Second))
+		if chunk < throttleMinChunk {
+			chunk = throttleMinChunk
+		}
+		if chunk > len(b) {
+			chunk = len(b)
+		}
+		c.down.wait(chunk, p.down)
+		n, err := c.Conn.Write(b[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[chunk:]
+	}
+	return written, nil
+}
+
+// SetLinger lets faults reset throttled connections.
+func (c *throttledConn) SetLinger(sec int) error {
+	if l, ok := c.Conn.(interface{ SetLinger(sec int) error }); ok {
+		return l.SetLinger(sec)
+	}
+	return nil
+}
+
+func bytesPerSecond(kbps int64) int64 {
+	return kbps * 1000 / 8
+}
+
+// bandwidth paces the transfers of one or more connections at a rate.
+type bandwidth struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until n bytes would have been transferred at kbps, after the
+// previous transfers.
+func (b *bandwidth) wait(n int, kbps int64) {
+	if kbps <= 0 {
+		return
+	}
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(int64(n) * int64(time.Second) / bytesPerSecond(kbps)))
+	delay := b.next.Sub(now)
+	b.mu.Unlock()
+	time.Sleep(delay)
+}
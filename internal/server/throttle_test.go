@@ -0,0 +1,56 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewThrottle(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   Configuration
+		expected *throttleProfile
+	}{
+		{"off", Configuration{}, nil},
+		{"preset", Configuration{Throttle: "3g"}, &throttlePresets[1]},
+		{
+			"preset with latency",
+			Configuration{Throttle: "4g", Latency: time.Second},
+			&throttleProfile{name: "4g*", down: 9000, up: 9000, latency: time.Second},
+		},
+		{
+			"custom",
+			Configuration{ThrottleDown: 100},
+			&throttleProfile{name: "custom", down: 100},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, newThrottle(tc.config).profile())
+		})
+	}
+}
+
+func TestThrottleNext(t *testing.T) {
+	th := newThrottle(Configuration{Throttle: "dsl"})
+	assert.Equal(t, "Throttling "+throttlePresets[4].String(), th.next())
+	assert.Equal(t, "Throttling off", th.next())
+	assert.Nil(t, th.profile())
+	assert.Equal(t, "Throttling "+throttlePresets[0].String(), th.next())
+}
+
+func TestBandwidth(t *testing.T) {
+	var b bandwidth
+	start := time.Now()
+	// 8 kbit/s is 1000 bytes per second
+	b.wait(100, 8)
+	b.wait(100, 8)
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
@@ -0,0 +1,162 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// treeFS is a read-only fs.FS built from a list of files, like the entries
+// of an archive or the objects of a git tree. Only the tree is kept in
+// memory, the content of each file is read when it is opened, and files
+// are seekable so http.FS can serve them with Range support.
+type treeFS struct {
+	nodes map[string]*treeNode
+}
+
+type treeNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	size     int64
+	open     func() (io.ReadSeeker, error)
+	children []*treeNode
+}
+
+func newTreeFS(modTime time.Time) *treeFS {
+	root := &treeNode{name: ".", mode: fs.ModeDir | 0o555, modTime: modTime}
+	return &treeFS{nodes: map[string]*treeNode{".": root}}
+}
+
+// addFile adds the file name, a slash-separated path that may start with
+// '/' or './'. Missing parent directories are created with the modification
+// time of the file. Paths can't escape the root, "../a" is added as "a".
+func (t *treeFS) addFile(name string, modTime time.Time, size int64, open func() (io.ReadSeeker, error)) {
+	if node := t.add(name, 0o444, modTime); node != nil {
+		node.size = size
+		node.open = open
+	}
+}
+
+// addDir adds the directory name, see addFile.
+func (t *treeFS) addDir(name string, modTime time.Time) {
+	t.add(name, fs.ModeDir|0o555, modTime)
+}
+
+func (t *treeFS) add(name string, mode fs.FileMode, modTime time.Time) *treeNode {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))[1:]
+	if name == "" || !fs.ValidPath(name) {
+		return nil
+	}
+	if node, ok := t.nodes[name]; ok {
+		// Explicit directory entries may come after their files, and
+		// later entries of the same file replace the former ones
+		if mode.IsDir() != node.IsDir() {
+			return nil
+		}
+		node.modTime = modTime
+		if node.IsDir() {
+			return nil
+		}
+		return node
+	}
+	parent := t.nodes[path.Dir(name)]
+	if parent == nil {
+		parent = t.add(path.Dir(name), fs.ModeDir|0o555, modTime)
+	}
+	if parent == nil || !parent.IsDir() {
+		return nil
+	}
+	node := &treeNode{name: path.Base(name), mode: mode, modTime: modTime}
+	// Keep children sorted, so listings don't need to
+	i := sort.Search(len(parent.children), func(i int) bool { return parent.children[i].name >= node.name })
+	parent.children = append(parent.children, nil)
+	copy(parent.children[i+1:], parent.children[i:])
+	parent.children[i] = node
+	t.nodes[name] = node
+	return node
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := t.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.IsDir() {
+		return &treeFile{node: node}, nil
+	}
+	content, err := node.open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFile{node: node, content: content}, nil
+}
+
+// treeNode is its own fs.FileInfo and fs.DirEntry.
+
+func (n *treeNode) Name() string               { return n.name }
+func (n *treeNode) Size() int64                { return n.size }
+func (n *treeNode) Mode() fs.FileMode          { return n.mode }
+func (n *treeNode) ModTime() time.Time         { return n.modTime }
+func (n *treeNode) IsDir() bool                { return n.mode.IsDir() }
+func (n *treeNode) Sys() any                   { return nil }
+func (n *treeNode) Type() fs.FileMode          { return n.mode.Type() }
+func (n *treeNode) Info() (fs.FileInfo, error) { return n, nil }
+
+type treeFile struct {
+	node    *treeNode
+	content io.ReadSeeker
+	offset  int
+}
+
+func (f *treeFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+
+func (f *treeFile) Read(p []byte) (int, error) {
+	if f.content == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: errors.New("is a directory")}
+	}
+	return f.content.Read(p)
+}
+
+func (f *treeFile) Seek(offset int64, whence int) (int64, error) {
+	if f.content == nil {
+		return 0, &fs.PathError{Op: "seek", Path: f.node.name, Err: errors.New("is a directory")}
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *treeFile) Close() error {
+	if c, ok := f.content.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (f *treeFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if f.content != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.node.name, Err: errors.New("not a directory")}
+	}
+	children := f.node.children[f.offset:]
+	if count > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(children) {
+		children = children[:count]
+	}
+	f.offset += len(children)
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = child
+	}
+	return entries, nil
+}
@@ -0,0 +1,73 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTreeFS(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	content := func(s string) func() (io.ReadSeeker, error) {
+		return func() (io.ReadSeeker, error) { return bytes.NewReader([]byte(s)), nil }
+	}
+	tree := newTreeFS(now)
+	tree.addFile("./index.html", now, 4, content("home"))
+	tree.addFile("/docs/api/v1.md", now, 2, content("v1"))
+	tree.addFile("docs/guide.md", now, 5, content("guide"))
+	tree.addDir("docs/", now.Add(-time.Hour))
+	tree.addDir("empty", now)
+	tree.addFile("../outside.txt", now, 3, content("out"))
+	tree.addFile("index.html", now, 5, content("home!"))
+
+	assert.NoError(t, fstest.TestFS(tree, "index.html", "docs/api/v1.md", "docs/guide.md", "empty"))
+
+	tt := []struct {
+		name     string
+		path     string
+		expected string
+		wantErr  bool
+	}{
+		{
+			"later entries replace the former ones",
+			"index.html",
+			"home!",
+			false,
+		},
+		{
+			"paths escaping the root are cleaned",
+			"outside.txt",
+			"out",
+			false,
+		},
+		{
+			"missing file",
+			"missing.txt",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := fs.ReadFile(tree, tc.path)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, fs.ErrNotExist)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(res))
+		})
+	}
+
+	info, err := fs.Stat(tree, "docs")
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-time.Hour), info.ModTime())
+}
@@ -0,0 +1,137 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// serveUpload saves the files of a multipart form posted to the directory
+// name. Parts are streamed to disk, and existing files are never replaced.
+// Forms larger than UploadMaxSize are rejected, removing the file that was
+// being saved.
+func (fh *fileHandler) serveUpload(w http.ResponseWriter, r *http.Request, name string) {
+	if fh.config.ReadOnly {
+		fh.writeError(w, r, "Uploads are disabled in read-only mode", http.StatusForbidden)
+		return
+	}
+	if info, err := fh.stat(name); err != nil {
+		fh.serveError(w, r, err)
+		return
+	} else if !info.IsDir() {
+		w.Header().Set("Allow", "GET, HEAD")
+		fh.writeError(w, r, "Files can only be uploaded to directories", http.StatusMethodNotAllowed)
+		return
+	}
+	if fh.config.UploadMaxSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, fh.config.UploadMaxSize)
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		fh.writeError(w, r, "Expected a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	var uploaded []string
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fh.uploadError(w, r, err, "Error reading the form", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		// Browsers send base names, but other clients may send anything,
+		// which FileName reduces to its base name
+		filename := part.FileName()
+		if _, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			filename = params["filename"]
+		}
+		filename = strings.ReplaceAll(filename, "\\", "/")
+		base := path.Base(filename)
+		target := path.Join(name, base)
+		if base == "." || base == "/" || hasDotDot(filename) || fh.ignore.match(target, false) {
+			fh.writeError(w, r, "Invalid file name "+part.FileName(), http.StatusForbidden)
+			return
+		}
+		if err = fh.saveUpload(target, part); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				fh.writeError(w, r, base+" already exists", http.StatusConflict)
+				return
+			}
+			if errors.As(err, new(*http.MaxBytesError)) {
+				fh.uploadError(w, r, err, "", 0)
+				return
+			}
+			log.Warn("Error saving upload", "path", target, "error", err)
+			fh.serveError(w, r, err)
+			return
+		}
+		log.Debug("File uploaded", "path", target)
+		uploaded = append(uploaded, target)
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string][]string{"uploaded": uploaded})
+		return
+	}
+	// Back to the listing, relative because mounts strip their prefix
+	location := "./"
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		location = path.Base(r.URL.Path) + "/"
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+func (fh *fileHandler) saveUpload(name string, content io.Reader) error {
+	file := filepath.Join(fh.config.Path, filepath.FromSlash(name))
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(file)
+		return err
+	}
+	return f.Close()
+}
+
+// uploadError replies to a form that couldn't be read with msg and code, or
+// with 413 if it is too large.
+func (fh *fileHandler) uploadError(w http.ResponseWriter, r *http.Request, err error, msg string, code int) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		msg = fmt.Sprintf("Uploads are limited to %s", humanizeSize(maxBytesErr.Limit))
+		code = http.StatusRequestEntityTooLarge
+	}
+	fh.writeError(w, r, msg, code)
+}
+
+// hasDotDot reports whether any segment of the '/'-separated name is "..".
+func hasDotDot(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeUpload(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   Configuration
+		filename string
+		content  string
+		accept   string
+		status   int
+		saved    string
+	}{
+		{"upload", Configuration{}, "new.txt", "new", "", http.StatusSeeOther, "new.txt"},
+		{"json reply", Configuration{}, "new.txt", "new", "application/json", http.StatusCreated, "new.txt"},
+		{"directories are dropped", Configuration{}, "sub/new.txt", "new", "", http.StatusSeeOther, "new.txt"},
+		{"read-only", Configuration{ReadOnly: true}, "new.txt", "new", "", http.StatusForbidden, ""},
+		{"existing file", Configuration{}, "existing.txt", "new", "", http.StatusConflict, ""},
+		{"parent directory", Configuration{}, "../escape.txt", "new", "", http.StatusForbidden, ""},
+		{"windows parent directory", Configuration{}, `..\escape.txt`, "new", "", http.StatusForbidden, ""},
+		{"ignored name", Configuration{}, ".env", "new", "", http.StatusForbidden, ""},
+		{"too large", Configuration{UploadMaxSize: 1024}, "big.txt", strings.Repeat("x", 2048), "", http.StatusRequestEntityTooLarge, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "root")
+			assert.NoError(t, os.Mkdir(dir, 0o755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("old"), 0o644))
+			config := tc.config
+			config.Path, config.Upload = dir, true
+			handler := FileServer(http.Dir(dir), config)
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			part, err := mw.CreateFormFile("files", tc.filename)
+			assert.NoError(t, err)
+			_, _ = part.Write([]byte(tc.content))
+			assert.NoError(t, mw.Close())
+			r := httptest.NewRequest(http.MethodPost, "/", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.accept == "application/json" {
+				assert.JSONEq(t, `{"uploaded":["/new.txt"]}`, w.Body.String())
+			}
+			existing, _ := os.ReadFile(filepath.Join(dir, "existing.txt"))
+			assert.Equal(t, "old", string(existing))
+			entries, _ := os.ReadDir(dir)
+			names := []string{}
+			for _, e := range entries {
+				if e.Name() != "existing.txt" {
+					names = append(names, e.Name())
+				}
+			}
+			if tc.saved == "" {
+				assert.Empty(t, names)
+				_, err := os.Stat(filepath.Join(parent, "escape.txt"))
+				assert.ErrorIs(t, err, os.ErrNotExist)
+				return
+			}
+			assert.Equal(t, []string{tc.saved}, names)
+			content, _ := os.ReadFile(filepath.Join(dir, tc.saved))
+			assert.Equal(t, tc.content, string(content))
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// VHost serves the requests for Host, either from its own mounts or from
+// an Upstream server. Hosts starting with "*." match any subdomain.
+type VHost struct {
+	Host     string
+	Mounts   []Mount
+	Upstream string
+}
+
+// ParseVHosts parses the virtual hosts given as host=path[:prefix[:options]]
+// or host=http://upstream. The mounts of a host can be given in several
+// specs, like the arguments of the local command.
+func ParseVHosts(specs []string, config Configuration) ([]VHost, error) {
+	var vhosts []VHost
+	mounts := map[string][]string{}
+	for _, spec := range specs {
+		host, target, ok := strings.Cut(spec, "=")
+		host = normalizeHost(host)
+		if !ok || host == "" || target == "" {
+			return nil, fmt.Errorf("virtual host %q: expected host=path or host=url", spec)
+		}
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			u, err := url.Parse(target)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("virtual host %q: invalid upstream %q", spec, target)
+			}
+			vhosts = append(vhosts, VHost{Host: host, Upstream: target})
+			continue
+		}
+		if _, ok := mounts[host]; !ok {
+			vhosts = append(vhosts, VHost{Host: host})
+		}
+		mounts[host] = append(mounts[host], target)
+	}
+
+	seen := map[string]bool{}
+	for i, vh := range vhosts {
+		if seen[vh.Host] {
+			return nil, fmt.Errorf("virtual host %s is given both an upstream and paths, or several upstreams", vh.Host)
+		}
+		seen[vh.Host] = true
+		if vh.Upstream != "" {
+			continue
+		}
+		m, err := ParseMounts(mounts[vh.Host], config)
+		if err != nil {
+			return nil, fmt.Errorf("virtual host %s: %w", vh.Host, err)
+		}
+		vhosts[i].Mounts = m
+	}
+	return vhosts, nil
+}
+
+// normalizeHost returns host in lower case, without port nor trailing dot.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// vhostHandler routes the requests by their Host header, and sends those
+// of unknown hosts to the default handler.
+type vhostHandler struct {
+	hosts     map[string]http.Handler
+	wildcards []vhostRoute
+	fallback  http.Handler
+}
+
+type vhostRoute struct {
+	suffix  string
+	handler http.Handler
+}
+
+// VHostServer returns the handler of the virtual hosts of config, with
+// fallback as default. Without virtual hosts, it returns fallback.
+func VHostServer(config Configuration, fallback http.Handler) http.Handler {
+	if len(config.VHosts) == 0 {
+		return fallback
+	}
+	vh := &vhostHandler{hosts: map[string]http.Handler{}, fallback: fallback}
+	for _, v := range config.VHosts {
+		var handler http.Handler
+		if v.Upstream != "" {
+			handler = upstreamProxy(v.Upstream)
+		} else {
+			hostConfig := config
+			hostConfig.VHosts = nil
+			hostConfig.Mounts = v.Mounts
+			handler = MountServer(hostConfig)
+		}
+		if suffix, ok := strings.CutPrefix(v.Host, "*"); ok {
+			vh.wildcards = append(vh.wildcards, vhostRoute{suffix: suffix, handler: handler})
+		} else {
+			vh.hosts[v.Host] = handler
+		}
+	}
+	// The most specific wildcards go first
+	sort.SliceStable(vh.wildcards, func(i, j int) bool {
+		return len(vh.wildcards[i].suffix) > len(vh.wildcards[j].suffix)
+	})
+	return vh
+}
+
+func (vh *vhostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := normalizeHost(r.Host)
+	if handler, ok := vh.hosts[host]; ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+	for _, route := range vh.wildcards {
+		if strings.HasSuffix(host, route.suffix) {
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	vh.fallback.ServeHTTP(w, r)
+}
+
+// upstreamProxy forwards the requests to the server at upstream, with its
+// own Host, as development servers often reject unknown ones. The original
+// one is sent in X-Forwarded-Host. Like external rewrites, credentials are
+// not forwarded: neither those of servant nor the client cookies.
+func upstreamProxy(upstream string) http.Handler {
+	target, _ := url.Parse(upstream)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		host := r.Host
+		director(r)
+		r.Host = target.Host
+		r.Header.Set("X-Forwarded-Host", host)
+		r.Header.Del("Authorization")
+		r.Header.Del("Proxy-Authorization")
+		r.Header.Del("Cookie")
+	}
+	return proxy
+}
@@ -0,0 +1,132 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVHosts(t *testing.T) {
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	assert.NoError(t, os.Mkdir(docs, 0o755))
+
+	tt := []struct {
+		name     string
+		specs    []string
+		expected []VHost
+		wantErr  bool
+	}{
+		{
+			"path",
+			[]string{"Docs.localhost:8080=" + docs},
+			[]VHost{{Host: "docs.localhost", Mounts: []Mount{{Path: docs, Prefix: "/"}}}},
+			false,
+		},
+		{
+			"several mounts",
+			[]string{"docs.localhost=" + docs, "docs.localhost=" + dir + ":/all:ro"},
+			[]VHost{{Host: "docs.localhost", Mounts: []Mount{
+				{Path: docs, Prefix: "/docs"},
+				{Path: dir, Prefix: "/all", ReadOnly: true},
+			}}},
+			false,
+		},
+		{
+			"upstream",
+			[]string{"*.app.localhost=http://localhost:3000"},
+			[]VHost{{Host: "*.app.localhost", Upstream: "http://localhost:3000"}},
+			false,
+		},
+		{"missing target", []string{"docs.localhost"}, nil, true},
+		{"missing path", []string{"docs.localhost=" + filepath.Join(dir, "missing")}, nil, true},
+		{"invalid upstream", []string{"app.localhost=http://"}, nil, true},
+		{"upstream and path", []string{"app.localhost=http://localhost:3000", "app.localhost=" + docs}, nil, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			vhosts, err := ParseVHosts(tc.specs, Configuration{})
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, vhosts)
+		})
+	}
+}
+
+func TestVHostServer(t *testing.T) {
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		})
+	}
+	vh := &vhostHandler{
+		hosts: map[string]http.Handler{"docs.localhost": named("docs")},
+		wildcards: []vhostRoute{
+			{suffix: ".api.localhost", handler: named("api")},
+			{suffix: ".localhost", handler: named("any")},
+		},
+		fallback: named("default"),
+	}
+
+	tt := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{"exact", "docs.localhost", "docs"},
+		{"port and case", "DOCS.localhost:8080", "docs"},
+		{"wildcard", "v1.api.localhost", "api"},
+		{"less specific wildcard", "app.localhost", "any"},
+		{"fallback", "127.0.0.1:8080", "default"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			w := httptest.NewRecorder()
+			vh.ServeHTTP(w, r)
+			assert.Equal(t, tc.expected, w.Body.String())
+		})
+	}
+}
+
+func TestUpstreamProxy(t *testing.T) {
+	var upstream *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r
+		_, _ = w.Write([]byte("upstream"))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	r := httptest.NewRequest(http.MethodGet, "/app?page=2", nil)
+	r.Host = "app.localhost"
+	r.SetBasicAuth("user", "secret")
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("Proxy-Authorization", "Basic x")
+	r.Header.Set("X-Custom", "kept")
+	w := httptest.NewRecorder()
+	upstreamProxy(server.URL).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "upstream", w.Body.String())
+	assert.Equal(t, "/app", upstream.URL.Path)
+	assert.Equal(t, "page=2", upstream.URL.RawQuery)
+	assert.Equal(t, target.Host, upstream.Host)
+	assert.Equal(t, "app.localhost", upstream.Header.Get("X-Forwarded-Host"))
+	assert.Empty(t, upstream.Header.Get("Authorization"))
+	assert.Empty(t, upstream.Header.Get("Cookie"))
+	assert.Empty(t, upstream.Header.Get("Proxy-Authorization"))
+	assert.Equal(t, "kept", upstream.Header.Get("X-Custom"))
+}
@@ -0,0 +1,178 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"context"
+	"github.com/charmbracelet/log"
+	"golang.org/x/net/webdav"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+)
+
+// webdavHandler serves the WebDAV methods through x/net/webdav and keeps
+// plain browser requests (GET/HEAD) on the regular file server, so the same
+// path can be both mounted from a WebDAV client and browsed.
+type webdavHandler struct {
+	files     http.Handler
+	dav       *webdav.Handler
+	prefix    string
+	readOnly  bool
+	noListing bool
+}
+
+func WebDAVServer(config Configuration, links symlinkPolicy, files http.Handler) Handler {
+	return &webdavHandler{
+		files: files,
+		dav: &webdav.Handler{
+			Prefix: config.Prefix,
+			FileSystem: davFileSystem{
+				FileSystem: webdav.Dir(config.Path),
+				rules:      loadIgnoreRules(http.Dir(config.Path), config),
+				links:      links,
+			},
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Debug("WebDAV error", "method", r.Method, "path", r.URL.Path, "error", err)
+				}
+			},
+		},
+		prefix:    config.Prefix,
+		readOnly:  config.ReadOnly,
+		noListing: config.NoListing,
+	}
+}
+
+func (wh *webdavHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		wh.files.ServeHTTP(w, r)
+		return
+	}
+	if wh.readOnly && isWriteMethod(r.Method) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if wh.noListing && wh.isListing(r) {
+		http.Error(w, "Directory listing is disabled", http.StatusForbidden)
+		return
+	}
+	if wh.prefix != "" {
+		// Mounts strip their prefix, but webdav needs it to resolve the
+		// Destination header of COPY and MOVE, and to build hrefs
+		r = r.Clone(r.Context())
+		r.URL.Path = wh.prefix + r.URL.Path
+		r.URL.RawPath = ""
+	}
+	wh.dav.ServeHTTP(w, r)
+}
+
+// isListing reports whether r is a PROPFIND of the members of a collection,
+// which is how WebDAV clients list directories. Without a Depth header it
+// goes all the way down.
+func (wh *webdavHandler) isListing(r *http.Request) bool {
+	if r.Method != "PROPFIND" || r.Header.Get("Depth") == "0" {
+		return false
+	}
+	info, err := wh.dav.FileSystem.Stat(r.Context(), r.URL.Path)
+	return err == nil && info.IsDir()
+}
+
+// isWriteMethod reports whether method may modify the served tree.
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return false
+	default:
+		return true
+	}
+}
+
+// davFileSystem applies the ignore rules and the symlink policy to WebDAV
+// clients, so they see the same tree as browsers. Ignored files can't be
+// read, listed nor written.
+type davFileSystem struct {
+	webdav.FileSystem
+	rules *ignoreRules
+	links symlinkPolicy
+}
+
+func (dfs davFileSystem) check(name string, isDir bool) error {
+	if dfs.rules.match(name, isDir) || !dfs.links.allows(name) {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (dfs davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if err := dfs.check(name, true); err != nil {
+		return err
+	}
+	return dfs.FileSystem.Mkdir(ctx, name, perm)
+}
+
+func (dfs davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	info, err := dfs.FileSystem.Stat(ctx, name)
+	if err = dfs.check(name, err == nil && info.IsDir()); err != nil {
+		return nil, err
+	}
+	f, err := dfs.FileSystem.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return davFile{File: f, name: name, rules: dfs.rules, links: dfs.links}, nil
+}
+
+func (dfs davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	info, err := dfs.FileSystem.Stat(ctx, name)
+	if err = dfs.check(name, err == nil && info.IsDir()); err != nil {
+		return err
+	}
+	return dfs.FileSystem.RemoveAll(ctx, name)
+}
+
+func (dfs davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	info, err := dfs.FileSystem.Stat(ctx, oldName)
+	isDir := err == nil && info.IsDir()
+	if err = dfs.check(oldName, isDir); err != nil {
+		return err
+	}
+	if err = dfs.check(newName, isDir); err != nil {
+		return err
+	}
+	return dfs.FileSystem.Rename(ctx, oldName, newName)
+}
+
+func (dfs davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := dfs.FileSystem.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = dfs.check(name, info.IsDir()); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type davFile struct {
+	webdav.File
+	name  string
+	rules *ignoreRules
+	links symlinkPolicy
+}
+
+func (f davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	list, err := f.File.Readdir(count)
+	visible := list[:0]
+	for _, info := range list {
+		name := path.Join(f.name, info.Name())
+		if !f.rules.match(name, info.IsDir()) && f.links.allows(name) {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
@@ -0,0 +1,219 @@
+// MIT Licensed
+// Copyright (c) 2023 Roberto García <roberto@planta7.io>
+
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVServer(t *testing.T) {
+	tt := []struct {
+		name     string
+		config   Configuration
+		method   string
+		target   string
+		header   http.Header
+		body     string
+		status   int
+		contains []string
+		missing  []string
+		exists   []string
+		gone     []string
+	}{
+		{
+			name:     "list",
+			method:   "PROPFIND",
+			target:   "/",
+			header:   http.Header{"Depth": {"1"}},
+			status:   http.StatusMultiStatus,
+			contains: []string{"<D:href>/a.txt</D:href>", "<D:href>/docs/</D:href>"},
+			missing:  []string{".env", "build.log", "outside.txt"},
+		},
+		{
+			name:   "ignored file",
+			method: "PROPFIND",
+			target: "/build.log",
+			header: http.Header{"Depth": {"0"}},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "link outside the root",
+			method: "PROPFIND",
+			target: "/outside.txt",
+			header: http.Header{"Depth": {"0"}},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "browsers get the file server",
+			method: http.MethodGet,
+			target: "/a.txt",
+			status: http.StatusOK,
+		},
+		{
+			name:   "upload",
+			method: http.MethodPut,
+			target: "/new.txt",
+			body:   "new",
+			status: http.StatusCreated,
+			exists: []string{"new.txt"},
+		},
+		{
+			name:   "upload of an ignored file",
+			method: http.MethodPut,
+			target: "/.env",
+			body:   "new",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "move",
+			method: "MOVE",
+			target: "/a.txt",
+			header: http.Header{"Destination": {"http://example.com/docs/b.txt"}},
+			status: http.StatusCreated,
+			exists: []string{"docs/b.txt"},
+			gone:   []string{"a.txt"},
+		},
+		{
+			name:   "move to an ignored name",
+			method: "MOVE",
+			target: "/a.txt",
+			header: http.Header{"Destination": {"http://example.com/a.log"}},
+			status: http.StatusForbidden,
+			exists: []string{"a.txt"},
+		},
+		{
+			name:   "read-only upload",
+			config: Configuration{ReadOnly: true},
+			method: http.MethodPut,
+			target: "/new.txt",
+			body:   "new",
+			status: http.StatusForbidden,
+			gone:   []string{"new.txt"},
+		},
+		{
+			name:   "read-only delete",
+			config: Configuration{ReadOnly: true},
+			method: http.MethodDelete,
+			target: "/a.txt",
+			status: http.StatusForbidden,
+			exists: []string{"a.txt"},
+		},
+		{
+			name:   "read-only list",
+			config: Configuration{ReadOnly: true},
+			method: "PROPFIND",
+			target: "/",
+			header: http.Header{"Depth": {"1"}},
+			status: http.StatusMultiStatus,
+		},
+		{
+			name:   "no listing",
+			config: Configuration{NoListing: true},
+			method: "PROPFIND",
+			target: "/docs/",
+			header: http.Header{"Depth": {"1"}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no listing without depth",
+			config: Configuration{NoListing: true},
+			method: "PROPFIND",
+			target: "/",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "no listing collection properties",
+			config: Configuration{NoListing: true},
+			method: "PROPFIND",
+			target: "/docs/",
+			header: http.Header{"Depth": {"0"}},
+			status: http.StatusMultiStatus,
+		},
+		{
+			name:   "no listing file properties",
+			config: Configuration{NoListing: true},
+			method: "PROPFIND",
+			target: "/a.txt",
+			header: http.Header{"Depth": {"1"}},
+			status: http.StatusMultiStatus,
+		},
+		{
+			name:     "mount list",
+			config:   Configuration{Mounts: []Mount{{Prefix: "/dav"}, {Prefix: "/other"}}},
+			method:   "PROPFIND",
+			target:   "/dav/",
+			header:   http.Header{"Depth": {"1"}},
+			status:   http.StatusMultiStatus,
+			contains: []string{"<D:href>/dav/a.txt</D:href>"},
+		},
+		{
+			name:   "mount copy",
+			config: Configuration{Mounts: []Mount{{Prefix: "/dav"}, {Prefix: "/other"}}},
+			method: "COPY",
+			target: "/dav/a.txt",
+			header: http.Header{"Destination": {"http://example.com/dav/docs/c.txt"}},
+			status: http.StatusCreated,
+			exists: []string{"a.txt", "docs/c.txt"},
+		},
+		{
+			name:   "mount nolist option",
+			config: Configuration{Mounts: []Mount{{Prefix: "/dav", NoListing: true}, {Prefix: "/other"}}},
+			method: "PROPFIND",
+			target: "/dav/",
+			header: http.Header{"Depth": {"1"}},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "mount read-only option",
+			config: Configuration{Mounts: []Mount{{Prefix: "/dav", ReadOnly: true}, {Prefix: "/other"}}},
+			method: http.MethodPut,
+			target: "/dav/new.txt",
+			status: http.StatusForbidden,
+			gone:   []string{"new.txt"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "root")
+			writeFiles(t, dir, map[string]string{"a.txt": "a", "docs/readme.md": "# docs", ".env": "SECRET=1", "build.log": "log"})
+			writeFiles(t, parent, map[string]string{"outside.txt": "outside"})
+			_ = os.Symlink(filepath.Join(parent, "outside.txt"), filepath.Join(dir, "outside.txt"))
+
+			config := tc.config
+			config.Path, config.WebDAV, config.Ignore = dir, true, []string{"*.log"}
+			for i := range config.Mounts {
+				config.Mounts[i].Path = dir
+			}
+			handler := MountServer(config)
+
+			r := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+			for _, s := range tc.contains {
+				assert.Contains(t, w.Body.String(), s)
+			}
+			for _, s := range tc.missing {
+				assert.NotContains(t, w.Body.String(), s)
+			}
+			for _, name := range tc.exists {
+				assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+			for _, name := range tc.gone {
+				assert.NoFileExists(t, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+		})
+	}
+}
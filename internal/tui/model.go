@@ -51,14 +51,28 @@ func newListKeyMap() *listKeyMap {
 	}
 }
 
+// Control is a setting of the server that can be changed with a key while
+// it runs. Change applies the next value and describes it.
+type Control struct {
+	Key    string
+	Help   string
+	Change func() string
+}
+
+type controlBinding struct {
+	binding key.Binding
+	change  func() string
+}
+
 type Model struct {
 	channel      chan item
 	list         list.Model
 	keys         *listKeyMap
 	delegateKeys *delegateKeyMap
+	controls     []controlBinding
 }
 
-func NewModel(info string) Model {
+func NewModel(info string, controls ...Control) Model {
 	var (
 		delegateKeys = newDelegateKeyMap()
 		listKeys     = newListKeyMap()
@@ -68,14 +82,28 @@ func NewModel(info string) Model {
 	requestList := list.New([]list.Item{}, delegate, 0, 0)
 	requestList.Title = info
 	requestList.Styles.Title = TitleStyle
+
+	var bindings []controlBinding
+	var controlKeys []key.Binding
+	for _, c := range controls {
+		binding := key.NewBinding(
+			key.WithKeys(c.Key),
+			key.WithHelp(c.Key, c.Help),
+		)
+		bindings = append(bindings, controlBinding{binding: binding, change: c.Change})
+		controlKeys = append(controlKeys, binding)
+	}
+	requestList.AdditionalShortHelpKeys = func() []key.Binding {
+		return controlKeys
+	}
 	requestList.AdditionalFullHelpKeys = func() []key.Binding {
-		return []key.Binding{
+		return append([]key.Binding{
 			listKeys.toggleSpinner,
 			listKeys.toggleTitleBar,
 			listKeys.toggleStatusBar,
 			listKeys.togglePagination,
 			listKeys.toggleHelpMenu,
-		}
+		}, controlKeys...)
 	}
 
 	return Model{
@@ -83,6 +111,7 @@ func NewModel(info string) Model {
 		list:         requestList,
 		keys:         listKeys,
 		delegateKeys: delegateKeys,
+		controls:     bindings,
 	}
 }
 
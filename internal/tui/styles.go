@@ -14,6 +14,7 @@ var (
 	Family5xx       = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "203", Dark: "204"})
 	DefaultStyle    = lipgloss.NewStyle().Foreground(lipgloss.NoColor{})
 	NewVersionStyle = Family2xx
+	FaultStyle      = Family5xx.Copy().Bold(true)
 
 	AppStyle           = lipgloss.NewStyle().Padding(1, 2)
 	TitleStyle         = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#04B575", Dark: "#ECFD65"})
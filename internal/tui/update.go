@@ -48,6 +48,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		for _, c := range m.controls {
+			if key.Matches(msg, c.binding) {
+				return m, m.list.NewStatusMessage(StatusMessageStyle(c.change()))
+			}
+		}
+
 	case item:
 		var lastItem list.Item
 		itemCount := len(m.list.Items())